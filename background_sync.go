@@ -0,0 +1,108 @@
+package kv_go
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 后台持久化
+// SyncWrites为false时，每写入BytesPerSync字节或者每隔SyncInterval，后台goroutine持久化一次活跃文件
+// 崩溃时最多丢失这段时间或者这么多字节的数据
+
+type backgroundSyncer struct {
+	bytesPerSync   int64
+	interval       time.Duration
+	bytesSinceSync int64 // 上次持久化之后写入的字节数
+	notifyCh       chan struct{}
+	closeCh        chan struct{}
+	closeOnce      *sync.Once
+	wg             *sync.WaitGroup
+	onError        func(error)
+	syncNum        uint64 // 后台持久化的次数
+}
+
+// 配置了BytesPerSync或者SyncInterval时启动后台持久化的goroutine
+func (db *DB) startBackgroundSync() {
+	config := db.config
+	if config.SyncWrites || config.ReadOnly || (config.BytesPerSync == 0 && config.SyncInterval <= 0) {
+		return
+	}
+
+	bs := &backgroundSyncer{
+		bytesPerSync: int64(config.BytesPerSync),
+		interval:     config.SyncInterval,
+		notifyCh:     make(chan struct{}, 1),
+		closeCh:      make(chan struct{}),
+		closeOnce:    new(sync.Once),
+		wg:           new(sync.WaitGroup),
+		onError:      config.OnSyncError,
+	}
+	db.backgroundSyncer = bs
+	bs.wg.Add(1)
+	go db.runBackgroundSync(bs)
+}
+
+func (db *DB) runBackgroundSync(bs *backgroundSyncer) {
+	defer bs.wg.Done()
+
+	var tickCh <-chan time.Time
+	if bs.interval > 0 {
+		ticker := time.NewTicker(bs.interval)
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-tickCh:
+		case <-bs.notifyCh:
+		case <-bs.closeCh:
+			return
+		}
+
+		// 这段时间没有写入数据
+		if atomic.SwapInt64(&bs.bytesSinceSync, 0) == 0 {
+			continue
+		}
+
+		db.mu.RLock()
+		activeFile := db.activeFile
+		db.mu.RUnlock()
+		if activeFile == nil {
+			continue
+		}
+		err := activeFile.Sync()
+		atomic.AddUint64(&bs.syncNum, 1)
+		if err != nil && bs.onError != nil {
+			bs.onError(err)
+		}
+	}
+}
+
+// 记录写入的字节数，达到BytesPerSync时通知后台goroutine，调用时需要持有db.mu
+func (db *DB) recordWrittenBytes(n int64) {
+	bs := db.backgroundSyncer
+	if bs == nil {
+		return
+	}
+	written := atomic.AddInt64(&bs.bytesSinceSync, n)
+	if bs.bytesPerSync > 0 && written >= bs.bytesPerSync {
+		select {
+		case bs.notifyCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 停止后台goroutine，等待正在进行的持久化完成，不能持有db.mu
+func (db *DB) stopBackgroundSync() {
+	bs := db.backgroundSyncer
+	if bs == nil {
+		return
+	}
+	bs.closeOnce.Do(func() {
+		close(bs.closeCh)
+	})
+	bs.wg.Wait()
+}
@@ -0,0 +1,75 @@
+package kv_go
+
+import (
+	"kv-go/utils"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_BytesPerSync(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-bytes-per-sync")
+	opts.DirPath = dir
+	opts.BytesPerSync = 64 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 写入的数据不够BytesPerSync
+	err = db.Put(utils.GetTestKey(0), utils.RandomValue(128))
+	assert.Nil(t, err)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, uint64(0), atomic.LoadUint64(&db.backgroundSyncer.syncNum))
+
+	for i := 1; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint64(&db.backgroundSyncer.syncNum) > 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestDB_SyncInterval(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-sync-interval")
+	opts.DirPath = dir
+	opts.SyncInterval = time.Millisecond * 20
+	syncErrCh := make(chan error, 10)
+	opts.OnSyncError = func(err error) {
+		syncErrCh <- err
+	}
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	// 没有写入时不持久化
+	time.Sleep(time.Millisecond * 60)
+	assert.Equal(t, uint64(0), atomic.LoadUint64(&db.backgroundSyncer.syncNum))
+
+	err = db.Put(utils.GetTestKey(0), utils.RandomValue(128))
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint64(&db.backgroundSyncer.syncNum) == 1
+	}, time.Second, time.Millisecond*10)
+
+	// 持久化失败时调用OnSyncError
+	err = db.Put(utils.GetTestKey(1), utils.RandomValue(128))
+	assert.Nil(t, err)
+	db.mu.Lock()
+	_ = db.activeFile.IOManager.Close()
+	db.mu.Unlock()
+	select {
+	case err := <-syncErrCh:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("sync error is not reported")
+	}
+
+	// Close停止后台goroutine，活跃文件已经被关闭了，Close会返回错误
+	_ = db.Close()
+	_ = os.RemoveAll(dir)
+}
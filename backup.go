@@ -0,0 +1,183 @@
+package kv_go
+
+import (
+	"io"
+	"kv-go/data"
+	"kv-go/fio"
+	"kv-go/index"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 在线备份
+// 和merge一样先切换活跃文件，切换之前的数据文件都不会再写入，硬链接到备份目录，不在同一个文件系统时拷贝
+// id最大的数据文件在打开备份时是活跃文件，需要拷贝，否则备份中的写入会修改原来的文件
+// hint文件和merge完成文件在加载merge文件时会被原地覆盖，不能硬链接，只能拷贝
+// merge的结果在下次Open时才替换数据目录中的文件，备份期间数据目录中的旧文件不会被删除
+
+// 备份到destDir，destDir不存在时会创建，已经存在时必须是空目录
+// 备份目录不包含文件锁，可以直接用Open打开
+func (db *DB) Backup(destDir string) error {
+	if db.config.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := prepareBackupDir(destDir); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	if db.activeFile == nil {
+		db.mu.Unlock()
+		return nil
+	}
+	// 活跃文件中有数据时才切换，空的活跃文件不需要备份
+	if db.activeFile.WriteOffset > 0 {
+		if err := db.activeFile.Sync(); err != nil {
+			db.mu.Unlock()
+			return err
+		}
+		db.olderFiles[db.activeFile.FileId] = db.activeFile
+		if err := db.setActiveDataFile(); err != nil {
+			db.mu.Unlock()
+			return err
+		}
+	}
+	// 小于这个id的数据文件都需要备份
+	backupFileId := db.activeFile.FileId
+	// b+树索引中已经有之后写入的位置，需要在锁中创建副本
+	var indexSnapshot index.Indexer
+	if db.config.IndexType == BPlusTree {
+		indexSnapshot = db.index.Clone()
+	}
+	seqNo := db.seqNo
+	db.mu.Unlock()
+
+	err := db.copyBackupFiles(destDir, backupFileId)
+	// 从备份打开之后分配的序列号也要比已经分配过的大
+	if err == nil {
+		err = copySeqNoLease(db.config.DirPath, destDir)
+	}
+	if err == nil && indexSnapshot != nil {
+		err = writeBackupIndex(destDir, indexSnapshot, seqNo)
+	}
+	if indexSnapshot != nil {
+		_ = indexSnapshot.Close()
+	}
+	return err
+}
+
+func prepareBackupDir(destDir string) error {
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrBackupDirNotEmpty
+	}
+	return nil
+}
+
+func (db *DB) copyBackupFiles(destDir string, backupFileId uint32) error {
+	entries, err := os.ReadDir(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+
+	// 需要备份的数据文件，id最大的一个打开备份时会成为活跃文件
+	var dataFileIds []int
+	for _, entry := range entries {
+		name := entry.Name()
+		srcPath := filepath.Join(db.config.DirPath, name)
+		dstPath := filepath.Join(destDir, name)
+
+		switch {
+		case strings.HasSuffix(name, data.DataFileSuffix):
+			fileId, err := strconv.Atoi(strings.TrimSuffix(name, data.DataFileSuffix))
+			if err != nil {
+				return ErrDataDirectoryCorrupted
+			}
+			if uint32(fileId) < backupFileId {
+				dataFileIds = append(dataFileIds, fileId)
+			}
+		case name == data.HintFileName || name == data.MergeFinishedFileName:
+			if err := copyFile(srcPath, dstPath); err != nil {
+				return err
+			}
+		}
+	}
+	sort.Ints(dataFileIds)
+
+	for i, fileId := range dataFileIds {
+		srcPath := data.GetDatafilePath(db.config.DirPath, uint32(fileId))
+		dstPath := data.GetDatafilePath(destDir, uint32(fileId))
+		// 备份中的活跃文件会被追加写入或者在恢复时截断，不能和原来的文件共用inode
+		if i == len(dataFileIds)-1 {
+			err = copyFile(srcPath, dstPath)
+		} else {
+			err = linkOrCopyFile(srcPath, dstPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// b+树索引副本和事务序列号写入备份目录
+func writeBackupIndex(destDir string, indexSnapshot index.Indexer, seqNo uint64) error {
+	writerTo, ok := indexSnapshot.(io.WriterTo)
+	if !ok {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(destDir, index.BPlusTreeIndexFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fio.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := writerTo.WriteTo(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return writeSeqNoFile(destDir, seqNo)
+}
+
+func linkOrCopyFile(srcPath, dstPath string) error {
+	if err := os.Link(srcPath, dstPath); err == nil {
+		return nil
+	}
+	return copyFile(srcPath, dstPath)
+}
+
+func copyFile(srcPath, dstPath string) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fio.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+	if err := dstFile.Sync(); err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
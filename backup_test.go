@@ -0,0 +1,173 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/index"
+	"kv-go/utils"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_Backup(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer func() {
+		destroyDB(db)
+	}()
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	// merge之后重启，备份目录中也有hint文件
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	for i := 1000; i < 1100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+
+	// 备份时继续写入
+	stopCh := make(chan struct{})
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 2000; ; i++ {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+		}
+	}()
+
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-backup-dest")
+	defer os.RemoveAll(backupDir)
+	err = db.Backup(backupDir)
+	close(stopCh)
+	wg.Wait()
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(backupDir, data.HintFileName))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(backupDir, fileLockName))
+	assert.True(t, os.IsNotExist(err))
+
+	backupOpts := opts
+	backupOpts.DirPath = backupDir
+	backupDB, err := Open(backupOpts)
+	assert.Nil(t, err)
+	for i := 0; i < 1100; i++ {
+		val, err := backupDB.Get(utils.GetTestKey(i))
+		if i < 100 {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+	assert.Nil(t, backupDB.Close())
+
+	// 备份目录不是空的
+	err = db.Backup(backupDir)
+	assert.Equal(t, ErrBackupDirNotEmpty, err)
+}
+
+func TestDB_BackupBPlusTree(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup-bptree")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	txn := db.Begin()
+	assert.Nil(t, txn.Put(utils.GetTestKey(100), utils.GetTestKey(100)))
+	assert.Nil(t, txn.Commit())
+
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-backup-bptree-dest")
+	defer os.RemoveAll(backupDir)
+	assert.Nil(t, db.Backup(backupDir))
+	// 备份之后的写入不在备份中
+	assert.Nil(t, db.Put(utils.GetTestKey(101), utils.GetTestKey(101)))
+
+	_, err = os.Stat(filepath.Join(backupDir, index.BPlusTreeIndexFileName))
+	assert.Nil(t, err)
+
+	backupOpts := opts
+	backupOpts.DirPath = backupDir
+	backupDB, err := Open(backupOpts)
+	assert.Nil(t, err)
+	defer backupDB.Close()
+	assert.Equal(t, db.seqNo, backupDB.seqNo)
+	for i := 0; i <= 100; i++ {
+		val, err := backupDB.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+	_, err = backupDB.Get(utils.GetTestKey(101))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestDB_BackupWriteDoesNotChangeSource(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup-write")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-backup-write-dest")
+	defer os.RemoveAll(backupDir)
+	assert.Nil(t, db.Backup(backupDir))
+	assert.Nil(t, db.Close())
+
+	sizes := make(map[string]int64)
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		assert.Nil(t, err)
+		sizes[entry.Name()] = info.Size()
+	}
+
+	// 在备份中写入，原来的数据文件不变
+	backupOpts := opts
+	backupOpts.DirPath = backupDir
+	backupDB, err := Open(backupOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, backupDB.Put([]byte("from-backup"), []byte("x")))
+	assert.Nil(t, backupDB.Close())
+
+	for name, size := range sizes {
+		info, err := os.Stat(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		assert.Equal(t, size, info.Size(), name)
+	}
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	_, err = db.Get([]byte("from-backup"))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
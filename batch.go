@@ -4,7 +4,7 @@ import (
 	"encoding/binary"
 	"kv-go/data"
 	"sync"
-	"sync/atomic"
+	"time"
 )
 
 // 流程：
@@ -38,6 +38,16 @@ func (db *DB) NewWriteBatch(config WriteBatchConfig) *WriteBatch {
 }
 
 func (wb *WriteBatch) Put(key []byte, value []byte) error {
+	return wb.PutWithTTL(key, value, 0)
+}
+
+// 暂存带过期时间的数据，过期时间从调用时开始计算
+func (wb *WriteBatch) PutWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	return wb.putWithExpire(key, value, expireAt(ttl))
+}
+
+// 暂存过期时间是unix纳秒时间戳的数据，导入数据时保留原来的过期时间
+func (wb *WriteBatch) putWithExpire(key []byte, value []byte, expire int64) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
@@ -46,8 +56,9 @@ func (wb *WriteBatch) Put(key []byte, value []byte) error {
 	defer wb.mu.Unlock()
 
 	logRecord := &data.LogRecord{
-		Key:   key,
-		Value: value,
+		Key:    key,
+		Value:  value,
+		Expire: expire,
 	}
 	// 暂存起来
 	wb.pendingWrites[string(key)] = logRecord
@@ -86,6 +97,10 @@ func (wb *WriteBatch) Commit() error {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
 
+	if wb.db.config.ReadOnly {
+		return ErrReadOnly
+	}
+
 	if len(wb.pendingWrites) == 0 {
 		return nil
 	}
@@ -95,24 +110,41 @@ func (wb *WriteBatch) Commit() error {
 	}
 
 	wb.db.mu.Lock()
-	defer wb.db.mu.Unlock()
+	ticket, err := wb.db.commitRecords(wb.pendingWrites, wb.config.SyncWrites)
+	wb.db.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	wb.pendingWrites = make(map[string]*data.LogRecord)
+
+	return wb.db.waitForSync(ticket)
+}
 
+// 用同一个序列号写入所有数据和一条事务完成的数据，然后更新内存索引
+// 返回组提交的写入编号，组提交时持久化之后才更新索引，需要在释放db.mu之后调用waitForSync
+// 调用时需要持有db.mu
+func (db *DB) commitRecords(records map[string]*data.LogRecord, syncWrites bool) (uint64, error) {
 	// 获取事务序列号
-	seqNo := atomic.AddUint64(&wb.db.seqNo, 1)
+	seqNo, err := db.nextSeqNo()
+	if err != nil {
+		return 0, err
+	}
 
 	// 储存位置信息
 	positions := make(map[string]*data.LogRecordPos)
 
 	// 遍历pendingWrites
-	for _, record := range wb.pendingWrites {
-		logRecordPos, err := wb.db.appendLogRecord(&data.LogRecord{
-			Key:   createLogRecordKeyWithSeq(record.Key, seqNo),
-			Value: record.Value,
-			Type:  record.Type,
+	for _, record := range records {
+		logRecordPos, err := db.appendLogRecord(&data.LogRecord{
+			Key:    createLogRecordKeyWithSeq(record.Key, seqNo),
+			Value:  record.Value,
+			Type:   record.Type,
+			Expire: record.Expire,
 		})
 
 		if err != nil {
-			return err
+			return 0, err
 		}
 		positions[string(record.Key)] = logRecordPos
 	}
@@ -123,38 +155,57 @@ func (wb *WriteBatch) Commit() error {
 		Type: data.LogRecordTxnFinished,
 	}
 
-	if _, err := wb.db.appendLogRecord(finishedRecord); err != nil {
-		return err
+	if _, err := db.appendLogRecord(finishedRecord); err != nil {
+		return 0, err
 	}
 
 	// 持久化
 
-	if wb.config.SyncWrites && wb.db.activeFile != nil {
-		if err := wb.db.activeFile.Sync(); err != nil {
-			return err
+	if syncWrites && db.activeFile != nil && db.groupCommitter == nil {
+		if err := db.activeFile.Sync(); err != nil {
+			return 0, err
 		}
 	}
 
-	//更新内存索引
-	for _, record := range wb.pendingWrites {
-		pos := positions[string(record.Key)]
-		var oldPos *data.LogRecordPos
-		if record.Type == data.LogRecordNormal {
-			oldPos = wb.db.index.Put(record.Key, pos)
-		}
-
-		if record.Type == data.LogRecordDeleted {
-			oldPos, _ = wb.db.index.Delete(record.Key)
-		}
-		if oldPos != nil {
-			wb.db.invalidSize += int64(oldPos.Size)
-			wb.db.InvalidPiece += 1
-		}
+	keys := make([][]byte, 0, len(records))
+	for _, record := range records {
+		keys = append(keys, record.Key)
 	}
 
-	wb.pendingWrites = make(map[string]*data.LogRecord)
+	// 和不开启组提交时一样，db配置了SyncWrites时批量写入也要持久化
+	ticket := db.syncTicket(syncWrites || db.config.SyncWrites, keys, func() {
+		//更新内存索引
+		for _, record := range records {
+			pos := positions[string(record.Key)]
+			var oldPos *data.LogRecordPos
+			if record.Type == data.LogRecordNormal {
+				oldPos = db.index.Put(record.Key, pos)
+			}
+
+			if record.Type == data.LogRecordDeleted {
+				oldPos, _ = db.index.Delete(record.Key)
+			}
+			if oldPos != nil {
+				db.invalidSize += int64(oldPos.Size)
+				db.InvalidPiece += 1
+			}
+			db.markKeyModified(record.Key, seqNo)
+		}
 
-	return nil
+		// txnFinKey已经写入并且持久化之后再通知watcher，一个事务只产生一个事件
+		if len(db.watchers) > 0 {
+			entries := make([]*EventEntry, 0, len(records))
+			for _, record := range records {
+				entry := &EventEntry{Type: EventPut, Key: record.Key, Value: record.Value}
+				if record.Type == data.LogRecordDeleted {
+					entry = &EventEntry{Type: EventDelete, Key: record.Key}
+				}
+				entries = append(entries, entry)
+			}
+			db.notifyWatchers(seqNo, entries)
+		}
+	})
+	return ticket, nil
 }
 
 func createLogRecordKeyWithSeq(key []byte, seqNo uint64) []byte {
@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestDB_WriteBatch1(t *testing.T) {
@@ -85,3 +86,28 @@ func TestDB_WriteBatch2(t *testing.T) {
 	// 校验序列号
 	assert.Equal(t, uint64(2), db.seqNo)
 }
+
+func TestDB_WriteBatchPutWithTTL(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-batch-ttl")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	err = wb.PutWithTTL(utils.GetTestKey(1), utils.RandomValue(10), 50*time.Millisecond)
+	assert.Nil(t, err)
+	err = wb.PutWithTTL(utils.GetTestKey(2), utils.RandomValue(10), time.Hour)
+	assert.Nil(t, err)
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val2, err := db.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.NotNil(t, val2)
+}
@@ -1,16 +1,28 @@
 package kv_go
 
+import "time"
+
 type Config struct{
 	DirPath string
 	DataFileSize int64
 	SyncWrites bool
 	IndexType IndexType
+	MMapAtStartup bool // 启动时是否使用mmap读取数据文件
+	ReadOnly bool // 只读模式，不加文件锁，可以和写入的进程同时打开同一个目录
+	ValueCacheBytes int64 // value缓存最多占用的字节数，0代表不使用缓存
+	GroupCommit bool // 需要持久化的并发写入共用一次Sync，写入的调用在数据持久化之后返回
+	BytesPerSync uint // SyncWrites为false时，每写入这么多字节后台持久化一次，0代表不按字节数持久化
+	SyncInterval time.Duration // SyncWrites为false时，每隔这么久后台持久化一次，0代表不定时持久化
+	OnSyncError func(err error) // 后台持久化失败时调用
+	StrictRecovery bool // 活跃文件末尾的数据损坏时直接返回错误，不截断文件
 }
 
 type IndexType = int8
 
 const (
 	Btree IndexType = iota + 1
+	ART // 自适应基数树，key前缀相同较多时更节省内存
+	BPlusTree // 磁盘b+树，索引不需要全部放在内存中，启动时也不需要加载索引
 )
 
 var DefaultConfig = Config{
@@ -18,6 +30,7 @@ var DefaultConfig = Config{
 	DataFileSize:       32 * 1024 * 1024, // 32MB
 	SyncWrites:         false,
 	IndexType:          Btree,
+	MMapAtStartup:      true,
 }
 
 type IteratorConfig struct{
@@ -39,3 +52,13 @@ var DefaultWriteBatchOptions = WriteBatchConfig{
 	MaxBatchNum: 10000,
 	SyncWrites:  true,
 }
+
+type ImportConfig struct{
+	BatchSize uint // 每个WriteBatch中最多写入的数据条数
+	SyncWrites bool // 每个WriteBatch提交时是否持久化
+}
+
+var DefaultImportConfig = ImportConfig{
+	BatchSize:  10000,
+	SyncWrites: false,
+}
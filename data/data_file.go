@@ -5,15 +5,29 @@ import (
 	"hash/crc32"
 	"io"
 	"kv-go/fio"
+	"os"
 	"path/filepath"
+	"sync"
 )
 
 const (
 	DataFileSuffix string = ".data"
 	HintFileName = "hint-index"
 	MergeFinishedFileName = "merge-finished"
+	SeqNoFileName = "seq-no"
+	SeqNoLeaseFileName = "seq-no-lease"
 )
 
+// ReadAt使用的缓冲区，超过maxPooledBufferSize的缓冲区用完之后不放回去
+const maxPooledBufferSize = 1 << 20
+
+var readBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 4096)
+		return &buf
+	},
+}
+
 type DataFile struct {
 	FileId      uint32
 	WriteOffset int64         // 文件写到了哪个位置
@@ -21,28 +35,40 @@ type DataFile struct {
 }
 
 //打开数据文件
-func OpenDataFile(dirPath string, fileId uint32) (*DataFile, error) {
+func OpenDataFile(dirPath string, fileId uint32, ioType fio.FileIOType) (*DataFile, error) {
 	filePath := GetDatafilePath(dirPath,fileId)
 	// 初始化iomanager
-	return newDataFile(filePath,fileId)
+	return newDataFile(filePath,fileId,ioType)
 }
 
 func OpenHintFile(dirPath string)(*DataFile,error){
 	filePath := filepath.Join(dirPath,HintFileName)
-	return newDataFile(filePath,0)
+	return newDataFile(filePath,0,fio.StandardFIO)
 }
 
 func OpenMergeFinishedFile(dirPath string)(*DataFile,error){
 	filePath := filepath.Join(dirPath,MergeFinishedFileName)
-	return newDataFile(filePath,0)
+	return newDataFile(filePath,0,fio.StandardFIO)
+}
+
+// 储存事务序列号的文件，b+树索引不遍历数据文件，需要在关闭时记录序列号
+func OpenSeqNoFile(dirPath string)(*DataFile,error){
+	filePath := filepath.Join(dirPath,SeqNoFileName)
+	return newDataFile(filePath,0,fio.StandardFIO)
+}
+
+// 储存序列号上限的文件，分配的序列号都不会超过这个值
+func OpenSeqNoLeaseFile(dirPath string)(*DataFile,error){
+	filePath := filepath.Join(dirPath,SeqNoLeaseFileName)
+	return newDataFile(filePath,0,fio.StandardFIO)
 }
 
 func GetDatafilePath(dirPath string, fileId uint32) string{
 	return filepath.Join(dirPath, fmt.Sprintf("%09d", fileId)+DataFileSuffix)
 }
 
-func newDataFile(filePath string, fileId uint32)(*DataFile,error){
-	ioManager, err := fio.NewIoManager(filePath)
+func newDataFile(filePath string, fileId uint32, ioType fio.FileIOType)(*DataFile,error){
+	ioManager, err := fio.NewIoManager(filePath, ioType)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +80,15 @@ func newDataFile(filePath string, fileId uint32)(*DataFile,error){
 }
 
 func (df *DataFile) Read(offset int64) (*LogRecord, int64, error) {
+	return df.readLogRecord(offset, true)
+}
+
+// 读取一条数据但不校验crc，返回的数据可能是损坏的，只用于修复数据时找出损坏数据的key
+func (df *DataFile) ReadUnchecked(offset int64) (*LogRecord, int64, error) {
+	return df.readLogRecord(offset, false)
+}
+
+func (df *DataFile) readLogRecord(offset int64, checkCRC bool) (*LogRecord, int64, error) {
 	//如果最后一条logrecord长度小于maxLogRecordHeaderSize，只需读到文件末尾，防止报eof
 	fileSize, err := df.IOManager.Size()
 	if err != nil {
@@ -85,8 +120,12 @@ func (df *DataFile) Read(offset int64) (*LogRecord, int64, error) {
 	// 取出key和value的长度
 	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
 	var recordSize = headerSize + keySize + valueSize
+	// header损坏时长度可能非常大，超出文件大小的数据不完整，不需要读取
+	if offset+recordSize > fileSize {
+		return nil, 0, io.EOF
+	}
 
-	logRecord := &LogRecord{Type: header.recordType}
+	logRecord := &LogRecord{Type: header.recordType, Expire: header.expire}
 	if keySize > 0 || valueSize > 0 {
 		//从offset+headerSize的位置读取keySize+valueSize长度
 		kvBuf, err := df.readNBytes(keySize+valueSize, offset+headerSize)
@@ -101,13 +140,61 @@ func (df *DataFile) Read(offset int64) (*LogRecord, int64, error) {
 	// 根据header的其余信息和key value重新计算crc并与储存的crc比较
 	// 不一致就代表数据被损坏了
 	crc := calcLogRecordCRC(logRecord, headerbuf[crc32.Size:headerSize])
-	if crc != header.crc {
+	if checkCRC && crc != header.crc {
 		return nil, 0, ErrInvalidCRC
 	}
 
 	return logRecord, recordSize, nil
 }
 
+// 根据索引中记录的位置和长度读取一条数据，只需要一次ReadAt
+// Read需要先获取文件大小，再分别读取header和key value
+func (df *DataFile) ReadAt(pos *LogRecordPos) (*LogRecord, error) {
+	size := int64(pos.Size)
+	if size <= crc32.Size {
+		logRecord, _, err := df.Read(pos.Offset)
+		return logRecord, err
+	}
+
+	bufPtr := readBufferPool.Get().(*[]byte)
+	if int64(cap(*bufPtr)) < size {
+		*bufPtr = make([]byte, size)
+	}
+	defer func() {
+		if cap(*bufPtr) <= maxPooledBufferSize {
+			readBufferPool.Put(bufPtr)
+		}
+	}()
+
+	buf := (*bufPtr)[:size]
+	if _, err := df.IOManager.Read(buf, pos.Offset); err != nil {
+		return nil, err
+	}
+
+	header, headerSize := decodeLogRecordHeader(buf)
+	if header == nil {
+		return nil, io.EOF
+	}
+	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
+	if headerSize <= crc32.Size || headerSize+keySize+valueSize != size {
+		return nil, ErrInvalidCRC
+	}
+	// header中crc之后的部分和key value是连续的，直接计算整段数据的crc
+	if crc32.ChecksumIEEE(buf[crc32.Size:]) != header.crc {
+		return nil, ErrInvalidCRC
+	}
+
+	// 缓冲区会被复用，key和value需要拷贝出来
+	kvBuf := make([]byte, keySize+valueSize)
+	copy(kvBuf, buf[headerSize:])
+	return &LogRecord{
+		Key:    kvBuf[:keySize],
+		Value:  kvBuf[keySize:],
+		Type:   header.recordType,
+		Expire: header.expire,
+	}, nil
+}
+
 func (df *DataFile) Sync() error {
 	return df.IOManager.Sync()
 }
@@ -125,6 +212,38 @@ func (df *DataFile) Close() error {
 	return df.IOManager.Close()
 }
 
+// 切换iomanager，比如启动时用mmap读取，之后切换回标准文件io写入
+func (df *DataFile) SetIOManager(dirPath string, ioType fio.FileIOType) error {
+	if err := df.IOManager.Close(); err != nil {
+		return err
+	}
+	ioManager, err := fio.NewIoManager(GetDatafilePath(dirPath, df.FileId), ioType)
+	if err != nil {
+		return err
+	}
+	df.IOManager = ioManager
+	return nil
+}
+
+// 把数据文件截断到size，丢弃末尾不完整的数据，截断之后使用标准文件io
+func (df *DataFile) Truncate(dirPath string, size int64) error {
+	if err := df.IOManager.Close(); err != nil {
+		return err
+	}
+	filePath := GetDatafilePath(dirPath, df.FileId)
+	if err := os.Truncate(filePath, size); err != nil {
+		return err
+	}
+	ioManager, err := fio.NewIoManager(filePath, fio.StandardFIO)
+	if err != nil {
+		return err
+	}
+	df.IOManager = ioManager
+	df.WriteOffset = size
+	// 截断之后的文件大小也需要持久化，否则崩溃之后可能又出现被丢弃的数据
+	return df.IOManager.Sync()
+}
+
 func (df *DataFile) readNBytes(n int64, offset int64) (b []byte, err error) {
 	b = make([]byte, n)
 	_, err = df.IOManager.Read(b, offset)
@@ -2,20 +2,71 @@ package data
 
 import (
 	
+	"kv-go/fio"
+	"os"
 	"testing"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOpenDataFile(t *testing.T){
-	datafile1, err := OpenDataFile("/Users/maike/Desktop/kv-database",111)
+	datafile1, err := OpenDataFile("/Users/maike/Desktop/kv-database",111,fio.StandardFIO)
 	assert.Nil(t,err)
 	assert.NotNil(t,datafile1)
 }
 
 func TestWriteDataFile(t *testing.T){
-	datafile1, err := OpenDataFile("/Users/maike/Desktop/kv-database",111)
+	datafile1, err := OpenDataFile("/Users/maike/Desktop/kv-database",111,fio.StandardFIO)
 	assert.Nil(t,err)
 	assert.NotNil(t,datafile1)
 	err = datafile1.Write([]byte{'c','a'})
 	assert.Nil(t,err)
-}
\ No newline at end of file
+}
+func TestDataFile_ReadAt(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	records := []*LogRecord{
+		{Key: []byte("name"), Value: []byte("kv-go")},
+		{Key: []byte("deleted"), Type: LogRecordDeleted},
+		{Key: []byte("ttl"), Value: []byte("value"), Expire: 123456789},
+		{Key: []byte("large"), Value: make([]byte, 8192)},
+	}
+	var positions []*LogRecordPos
+	for _, record := range records {
+		buf, size := EncodeLogRecord(record)
+		positions = append(positions, &LogRecordPos{Offset: dataFile.WriteOffset, Size: uint32(size)})
+		err := dataFile.Write(buf)
+		assert.Nil(t, err)
+	}
+
+	for i, pos := range positions {
+		record, err := dataFile.ReadAt(pos)
+		assert.Nil(t, err)
+		assert.Equal(t, records[i].Key, record.Key)
+		assert.Equal(t, len(records[i].Value), len(record.Value))
+		assert.Equal(t, records[i].Type, record.Type)
+		assert.Equal(t, records[i].Expire, record.Expire)
+
+		// 和Read的结果一致
+		record2, size, err := dataFile.Read(pos.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(pos.Size), size)
+		assert.Equal(t, record2.Value, record.Value)
+	}
+
+	// 数据损坏时校验crc失败
+	buf, _ := EncodeLogRecord(&LogRecord{Key: []byte("k"), Value: []byte("v")})
+	buf[len(buf)-1] = 'x'
+	offset := dataFile.WriteOffset
+	err = dataFile.Write(buf)
+	assert.Nil(t, err)
+	_, err = dataFile.ReadAt(&LogRecordPos{Offset: offset, Size: uint32(len(buf))})
+	assert.Equal(t, ErrInvalidCRC, err)
+
+	// 长度和header不一致
+	_, err = dataFile.ReadAt(&LogRecordPos{Offset: positions[0].Offset, Size: positions[0].Size + 1})
+	assert.Equal(t, ErrInvalidCRC, err)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"hash/crc32"
+	"time"
 )
 
 type LogRecordType = byte
@@ -17,20 +18,26 @@ const (
 	LogRecordTxnFinished
 )
 
+// type字节的最高位标记header中是否有过期时间
+// 没有过期时间的记录和加入过期时间之前的格式完全一样，旧的数据目录不需要迁移
+const logRecordExpireFlag byte = 0x80
+
 // logrecord header
 type logRecordHeader struct{
 	crc uint32 
 	recordType LogRecordType
 	keySize uint32
 	valueSize uint32
+	expire int64
 }
-// header长度 crc 4 byte, type 1 byte, keySize 和 valueSize 最长是5byte 
-const maxLogRecordHeaderSize =  binary.MaxVarintLen32 * 2 + 4 + 1
+// header长度 crc 4 byte, type 1 byte, keySize 和 valueSize 最长是5byte, expire 最长是10byte，只有设置了过期时间才写入
+const maxLogRecordHeaderSize =  binary.MaxVarintLen32 * 2 + binary.MaxVarintLen64 + 4 + 1
 // 写入磁盘数据格式
 type LogRecord struct{
 	Key []byte
 	Value []byte
 	Type LogRecordType
+	Expire int64 // 过期时间 unix纳秒时间戳，0代表永不过期
 }
 
 // 写入索引数据格式
@@ -38,6 +45,7 @@ type LogRecordPos struct{
 	Fid uint32 //文件id 哪个文件
 	Offset int64 // 文件里位置
 	Size uint32 // 磁盘上面大小, 用于统计无效数据长度
+	Expire int64 // 过期时间，和logrecord中的一致，放在内存中避免读磁盘判断是否过期
 }
 
 type TransactionRecord struct{
@@ -58,6 +66,10 @@ func EncodeLogRecord(logRecord *LogRecord) ([]byte,int64){
 	// 对keysize编码，把int转换为byte，返回写入的byte的长度, 具体编码原理https://segmentfault.com/a/1190000020500985
 	index = index + binary.PutVarint(header[index:],int64(len(logRecord.Key)))
 	index = index + binary.PutVarint(header[index:],int64(len(logRecord.Value)))
+	if logRecord.Expire != 0 {
+		header[4] |= logRecordExpireFlag
+		index = index + binary.PutVarint(header[index:],logRecord.Expire)
+	}
 
 	//整条记录的长度
 	var size = index + len(logRecord.Key) + len(logRecord.Value)
@@ -87,21 +99,58 @@ func decodeLogRecordHeader(buf []byte)(*logRecordHeader,int64){
 	header := &logRecordHeader{
 		// 把crc从四个字节的数组转换为uint32
 		crc : binary.LittleEndian.Uint32(buf[:4]),
-		recordType: buf[4],
+		recordType: buf[4] &^ logRecordExpireFlag,
 	}
 	var index = 5 
 	// 读取keysize
 	keySize,n := binary.Varint(buf[index:])
 	header.keySize = uint32(keySize)
 	index = index + n
+	// n <= 0 代表header不完整或者已经损坏
+	if n <= 0 {
+		return nil,0
+	}
 
 	// header
 	valueSize,n := binary.Varint(buf[index:])
 	header.valueSize = uint32(valueSize)
 	index = index + n
+	if n <= 0 {
+		return nil,0
+	}
+
+	// 过期时间，旧格式的记录没有
+	if buf[4]&logRecordExpireFlag != 0 {
+		expire,n := binary.Varint(buf[index:])
+		header.expire = expire
+		index = index + n
+		if n <= 0 {
+			return nil,0
+		}
+	}
 	return header, int64(index)
 }
 
+// buf开头是否是一条完整并且crc正确的数据，用于在内存中查找损坏位置之后的有效数据
+func IsValidLogRecord(buf []byte) bool {
+	header, headerSize := decodeLogRecordHeader(buf)
+	if header == nil {
+		return false
+	}
+	if header.crc == 0 && header.keySize == 0 && header.valueSize == 0 {
+		return false
+	}
+	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
+	if headerSize+keySize+valueSize > int64(len(buf)) {
+		return false
+	}
+	logRecord := &LogRecord{
+		Key:   buf[headerSize : headerSize+keySize],
+		Value: buf[headerSize+keySize : headerSize+keySize+valueSize],
+	}
+	return calcLogRecordCRC(logRecord, buf[crc32.Size:headerSize]) == header.crc
+}
+
 //计算crc,residualHeader是去除了crc的header
 func calcLogRecordCRC(lr *LogRecord, residualHeader[]byte)uint32{
 	if lr == nil {
@@ -115,11 +164,12 @@ func calcLogRecordCRC(lr *LogRecord, residualHeader[]byte)uint32{
 
 //对logrecordpos编码
 func EncodeLogRecordPos(pos *LogRecordPos) []byte{
-	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64)
+	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64*2)
 	var index = 0 
 	index += binary.PutVarint(buf[index:],int64(pos.Fid))
 	index += binary.PutVarint(buf[index:],pos.Offset)
 	index += binary.PutVarint(buf[index:],int64(pos.Size))
+	index += binary.PutVarint(buf[index:],pos.Expire)
 	return buf[:index]
 }
 
@@ -128,12 +178,28 @@ func DecodeLogRecordPos(buf []byte) *LogRecordPos{
 	var index = 0
 	fileId, n := binary.Varint(buf[index:])
 	index += n
-	offset,_ := binary.Varint(buf[index:])
+	offset,n := binary.Varint(buf[index:])
 	index += n 
-	size,_ := binary.Varint(buf[index:])
+	size,n := binary.Varint(buf[index:])
+	index += n
+	expire,_ := binary.Varint(buf[index:])
 	return &LogRecordPos{
 		Fid: uint32(fileId),
 		Offset: offset,
 		Size: uint32(size),
+		Expire: expire,
 	}
+}
+
+// 判断过期时间是否已经到了，expire为0代表永不过期
+func IsExpired(expire int64) bool{
+	return expire > 0 && expire <= time.Now().UnixNano()
+}
+
+func (lr *LogRecord) IsExpired() bool{
+	return IsExpired(lr.Expire)
+}
+
+func (pos *LogRecordPos) IsExpired() bool{
+	return IsExpired(pos.Expire)
 }
\ No newline at end of file
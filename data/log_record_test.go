@@ -34,3 +34,50 @@ func TestDecodeLogRecord(t *testing.T){
 	assert.Equal(t,logRecordHeader.recordType,rec1.Type)
 }
 
+
+func TestDecodeLogRecord_Expire(t *testing.T){
+	// 设置了过期时间
+	rec1 := &LogRecord{
+		Key: []byte("name"),
+		Value: []byte("hello"),
+		Type: LogRecordDeleted,
+		Expire: 1760000000000000000,
+	}
+	res1,_:= EncodeLogRecord(rec1)
+	header1,_:= decodeLogRecordHeader(res1)
+	assert.Equal(t,rec1.Type,header1.recordType)
+	assert.Equal(t,rec1.Expire,header1.expire)
+
+	// 加入过期时间之前的格式：crc type keySize valueSize key value
+	old := []byte{0, 0, 0, 0, LogRecordNormal, 8, 10}
+	old = append(old, []byte("namehello")...)
+	header2,size2:= decodeLogRecordHeader(old)
+	assert.Equal(t,LogRecordNormal,header2.recordType)
+	assert.Equal(t,uint32(4),header2.keySize)
+	assert.Equal(t,uint32(5),header2.valueSize)
+	assert.Equal(t,int64(0),header2.expire)
+	assert.Equal(t,int64(7),size2)
+
+	// 没有过期时间的记录和旧格式相同
+	rec3 := &LogRecord{Key: []byte("name"), Value: []byte("hello"), Type: LogRecordNormal}
+	res3,_:= EncodeLogRecord(rec3)
+	assert.Equal(t,old[4:],res3[4:])
+}
+
+func TestIsValidLogRecord(t *testing.T){
+	rec := &LogRecord{Key: []byte("name"), Value: []byte("hello"), Type: LogRecordNormal}
+	res,_:= EncodeLogRecord(rec)
+	assert.True(t,IsValidLogRecord(res))
+	// 后面还有其他数据
+	assert.True(t,IsValidLogRecord(append(res,1,2,3)))
+
+	// 不完整
+	assert.False(t,IsValidLogRecord(res[:len(res)-1]))
+	assert.False(t,IsValidLogRecord(res[:3]))
+	// crc不一致
+	broken := append([]byte{},res...)
+	broken[len(broken)-1] ^= 0xff
+	assert.False(t,IsValidLogRecord(broken))
+	// 全是0
+	assert.False(t,IsValidLogRecord(make([]byte,20)))
+}
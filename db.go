@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"kv-go/data"
+	"kv-go/fio"
 	"kv-go/index"
 	"os"
 	"path/filepath"
@@ -11,8 +12,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/flock"
 )
 
+const fileLockName = "flock"
+
 // 储存引擎实例
 type DB struct {
 	config       Config
@@ -22,16 +29,37 @@ type DB struct {
 	olderFiles   map[uint32]*data.DataFile
 	index        index.Indexer //内存索引
 	seqNo        uint64        // 事务序列号 递增
+	seqNoLease   uint64        // 已经持久化的序列号上限
+	seqNoFloor   uint64        // 上一次运行的序列号上限，新分配的序列号都比它大
 	isMerging    bool          // 是否在merge中
 	invalidSize  int64         //无效数据大小
 	InvalidPiece int64         //多少条无效数据
+	fileLock     *flock.Flock  // 文件锁，保证同一个目录只被一个进程打开
+
+	// 只读模式下还没有读到LogRecordTxnFinished的事务数据
+	pendingTxnRecords map[uint64][]*data.TransactionRecord
+
+	mergePending bool // merge已经完成，merge文件在下次Open时加载
+
+	activeTxnNum int               // 还没有结束的事务数量
+	keyVersions  map[string]uint64 // 有事务在运行时，记录每个key最后一次被修改时的序列号
+
+	watchers map[*watcher]struct{} // 通过Watch订阅修改的watcher
+
+	valueCache *valueCache // 最近读取的value，没有配置ValueCacheBytes时为nil
+
+	groupCommitter *groupCommitter // 没有开启GroupCommit时为nil
+
+	backgroundSyncer *backgroundSyncer // 没有配置BytesPerSync和SyncInterval时为nil
 }
 
 type Stat struct {
-	KeyNum       uint  // key的总数量
+	KeyNum       uint  // 索引中key的数量，包含已经过期但还没有被merge清理的key
 	DataFileNum  uint  //数据文件数量
 	InvalidSize  int64 //无效数据 以byte为单位
 	InvalidPiece int64
+	CacheHits    uint64 // value缓存命中次数
+	CacheMisses  uint64 // value缓存未命中次数
 }
 
 // 开启数据库
@@ -41,25 +69,73 @@ func Open(config Config) (*DB, error) {
 		return nil, err
 	}
 
+	// 只读模式下不能和写入的进程共用b+树索引文件，使用内存索引从数据文件中加载
+	if config.ReadOnly && config.IndexType == BPlusTree {
+		config.IndexType = Btree
+	}
+
 	// 判断数据目录是否存在，不存在就创建
-	if _, err := os.Stat(config.DirPath); os.IsNotExist(err) {
+	if _, err := os.Stat(config.DirPath); os.IsNotExist(err) && !config.ReadOnly {
 		if err := os.MkdirAll(config.DirPath, os.ModePerm); err != nil {
 			return nil, err
 		}
 	}
 
+	// 判断数据目录是否正在被其他进程使用，只读模式不加锁
+	var fileLock *flock.Flock
+	if !config.ReadOnly {
+		fileLock = flock.New(filepath.Join(config.DirPath, fileLockName))
+		hold, err := fileLock.TryLock()
+		if err != nil {
+			return nil, err
+		}
+		if !hold {
+			return nil, ErrDatabaseIsUsing
+		}
+	}
+	// 打开失败时释放文件锁，修复数据之后可以重新打开
+	opened := false
+	defer func() {
+		if !opened && fileLock != nil {
+			_ = fileLock.Unlock()
+		}
+	}()
+
+	indexer, err := index.NewIndexer(config.IndexType, config.DirPath, config.SyncWrites)
+	if err != nil {
+		return nil, err
+	}
+	// 打开失败时关闭索引，b+树索引文件的锁也要释放
+	defer func() {
+		if !opened {
+			_ = indexer.Close()
+		}
+	}()
+
 	// 初始化db实例
 	db := &DB{
 		config:     config,
 		mu:         new(sync.RWMutex),
 		olderFiles: make(map[uint32]*data.DataFile),
-		index:      index.NewIndexer(config.IndexType),
+		index:      indexer,
+		fileLock:   fileLock,
+
+		pendingTxnRecords: make(map[uint64][]*data.TransactionRecord),
+		keyVersions:       make(map[string]uint64),
+		watchers:          make(map[*watcher]struct{}),
+	}
+	if config.ValueCacheBytes > 0 {
+		db.valueCache = newValueCache(config.ValueCacheBytes)
+	}
+	if config.GroupCommit {
+		db.groupCommitter = newGroupCommitter()
 	}
 
-	// merge
-	err := db.loadMergeFiles()
-	if err != nil {
-		return nil, err
+	// merge，只读模式下不修改数据目录
+	if !config.ReadOnly {
+		if err := db.loadMergeFiles(); err != nil {
+			return nil, err
+		}
 	}
 
 	//加载数据文件
@@ -67,46 +143,110 @@ func Open(config Config) (*DB, error) {
 		return nil, err
 	}
 
-	//从hintfile文件中加载索引，因为hintfile不储存value，体积会比较小，加载也更快
-	if err := db.loadIndexFromHintFile(); err != nil {
-		return nil, err
+	// b+树索引储存在磁盘上，不需要从hintfile和数据文件中加载
+	if config.IndexType == BPlusTree {
+		if err := db.loadSeqNo(); err != nil {
+			return nil, err
+		}
+		if err := db.recoverBPlusTreeActiveFile(); err != nil {
+			return nil, err
+		}
+	} else {
+		//从hintfile文件中加载索引，因为hintfile不储存value，体积会比较小，加载也更快
+		if err := db.loadIndexFromHintFile(); err != nil {
+			return nil, err
+		}
+
+		if err := db.initIndex(); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := db.initIndex(); err != nil {
-		return nil, err
+	// 普通写入的序列号不在数据文件中，从持久化的上限继续分配
+	if !config.ReadOnly {
+		if err := db.loadSeqNoLease(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 索引构建完成，mmap切换回标准文件io
+	if config.MMapAtStartup {
+		if err := db.resetIoType(); err != nil {
+			return nil, err
+		}
 	}
+
+	db.startBackgroundSync()
+	opened = true
 	return db, nil
 }
 
+// 把所有数据文件的iomanager设置为标准文件io
+func (db *DB) resetIoType() error {
+	if db.activeFile == nil {
+		return nil
+	}
+	if err := db.activeFile.SetIOManager(db.config.DirPath, fio.StandardFIO); err != nil {
+		return err
+	}
+	for _, dataFile := range db.olderFiles {
+		if err := dataFile.SetIOManager(db.config.DirPath, fio.StandardFIO); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *DB) Put(key []byte, value []byte) error {
+	return db.PutWithTTL(key, value, 0)
+}
+
+// 写入带过期时间的数据，ttl <= 0 代表永不过期
+func (db *DB) PutWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	if db.config.ReadOnly {
+		return ErrReadOnly
+	}
 	// 判断key是否有效
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
 
 	log_record := data.LogRecord{
-		Key:   createLogRecordKeyWithSeq(key, nonTxnSeqNo),
-		Value: value,
-		Type:  data.LogRecordNormal,
+		Key:    createLogRecordKeyWithSeq(key, nonTxnSeqNo),
+		Value:  value,
+		Type:   data.LogRecordNormal,
+		Expire: expireAt(ttl),
 	}
-	//写入磁盘
-	pos, err := db.appendLogRecordWithLock(&log_record)
+	// 写入磁盘和更新内存都在锁中完成，事务检测冲突时不会漏掉正在写入的key
+	db.mu.Lock()
+
+	seqNo, err := db.nextNonTxnSeqNo()
 	if err != nil {
+		db.mu.Unlock()
 		return err
 	}
 
-	//写入内存
-	if oldPos := db.index.Put(key, pos); oldPos != nil {
-		db.invalidSize += int64(oldPos.Size)
-		db.InvalidPiece += 1
+	//写入磁盘
+	pos, err := db.appendLogRecord(&log_record)
+	if err != nil {
+		db.mu.Unlock()
+		return err
 	}
-	return nil
-}
 
-func (db *DB) appendLogRecordWithLock(logRecord *data.LogRecord) (*data.LogRecordPos, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	return db.appendLogRecord(logRecord)
+	//写入内存，组提交时持久化之后再更新
+	ticket := db.syncTicket(db.config.SyncWrites, [][]byte{key}, func() {
+		if oldPos := db.index.Put(key, pos); oldPos != nil {
+			db.invalidSize += int64(oldPos.Size)
+			db.InvalidPiece += 1
+		}
+
+		db.markKeyModified(key, seqNo)
+		db.notifyWatchers(seqNo, []*EventEntry{{Type: EventPut, Key: key, Value: value}})
+	})
+	db.mu.Unlock()
+
+	// 组提交时释放锁之后再持久化
+	return db.waitForSync(ticket)
 }
 
 // 写入磁盘
@@ -140,18 +280,27 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 	if err := db.activeFile.Write(encRecord); err != nil {
 		return nil, err
 	}
+	db.recordWrittenBytes(size)
 
-	// 是否持久化
-	if db.config.SyncWrites {
+	// 是否持久化，组提交时由写入的调用者在释放锁之后统一持久化
+	if db.config.SyncWrites && db.groupCommitter == nil {
 		if err := db.activeFile.Sync(); err != nil {
 			return nil, err
 		}
 	}
 
-	pos := &data.LogRecordPos{Fid: db.activeFile.FileId, Offset: offset, Size: uint32(size)}
+	pos := &data.LogRecordPos{Fid: db.activeFile.FileId, Offset: offset, Size: uint32(size), Expire: logRecord.Expire}
 	return pos, nil
 }
 
+// 根据ttl计算过期的时间戳
+func expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
 // 创建新的活跃文件
 func (db *DB) setActiveDataFile() error {
 	var initialFileId uint32 = 0
@@ -159,7 +308,7 @@ func (db *DB) setActiveDataFile() error {
 		initialFileId = db.activeFile.FileId + 1
 	}
 
-	dataFile, err := data.OpenDataFile(db.config.DirPath, initialFileId)
+	dataFile, err := data.OpenDataFile(db.config.DirPath, initialFileId, fio.StandardFIO)
 
 	if err != nil {
 		return err
@@ -179,8 +328,8 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 
 	//先从内存（btree）中取出key对应的信息
 	logRecordPos := db.index.Get(key)
-	//key不存在
-	if logRecordPos == nil {
+	//key不存在或者已经过期
+	if logRecordPos == nil || logRecordPos.IsExpired() {
 		return nil, ErrKeyNotFound
 	}
 
@@ -197,18 +346,35 @@ func (db *DB) getValueByPosition(logRecordPos *data.LogRecordPos) ([]byte, error
 		dataFile = db.olderFiles[logRecordPos.Fid] //获取旧的文件
 	}
 
+	if db.valueCache == nil {
+		return readValue(dataFile, logRecordPos)
+	}
+
+	if value, ok := db.valueCache.get(logRecordPos); ok {
+		return value, nil
+	}
+	value, err := readValue(dataFile, logRecordPos)
+	if err != nil {
+		return nil, err
+	}
+	db.valueCache.put(logRecordPos, value)
+	return value, nil
+}
+
+// 从数据文件中读取logrecordpos对应的value
+func readValue(dataFile *data.DataFile, logRecordPos *data.LogRecordPos) ([]byte, error) {
 	if dataFile == nil {
 		return nil, ErrDataFileNotFound
 	}
 
-	// 根据偏移读取数据
-	logRecord, _, err := dataFile.Read(logRecordPos.Offset)
+	// 根据位置和长度一次读取整条数据
+	logRecord, err := dataFile.ReadAt(logRecordPos)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if logRecord.Type == data.LogRecordDeleted {
+	if logRecord.Type == data.LogRecordDeleted || logRecord.IsExpired() {
 		return nil, ErrKeyNotFound
 	}
 
@@ -224,35 +390,29 @@ func checkConfig(config Config) error {
 		return errors.New("data file size must be greater than 0")
 	}
 
+	if config.ValueCacheBytes < 0 {
+		return errors.New("value cache bytes must not be negative")
+	}
+
 	return nil
 }
 
 // 从磁盘中加载数据文件
 // 把文件都放进db实例中
 func (db *DB) loadDataFiles() error {
-	dirEntries, err := os.ReadDir(db.config.DirPath)
+	fileIds, err := getDataFileIds(db.config.DirPath)
 	if err != nil {
 		return err
 	}
-
-	var fileIds []int
-
-	for _, entry := range dirEntries {
-		if strings.HasSuffix(entry.Name(), data.DataFileSuffix) {
-			fileId, err := strconv.Atoi(strings.Split(entry.Name(), ".")[0])
-			if err != nil {
-				return ErrDataDirectoryCorrupted
-			}
-			fileIds = append(fileIds, fileId)
-		}
-	}
-
-	// 对文件id排序
-	sort.Ints(fileIds)
 	db.fileIds = fileIds
+	// 启动时可以用mmap读取数据文件，加快构建索引的速度
+	ioType := fio.StandardFIO
+	if db.config.MMapAtStartup {
+		ioType = fio.MemoryMap
+	}
 	//遍历并打开每个文件(缺点)
 	for i, fid := range fileIds {
-		dataFile, err := data.OpenDataFile(db.config.DirPath, uint32(fid))
+		dataFile, err := data.OpenDataFile(db.config.DirPath, uint32(fid), ioType)
 		if err != nil {
 			return err
 		}
@@ -266,6 +426,30 @@ func (db *DB) loadDataFiles() error {
 	return nil
 }
 
+// 获取目录中所有数据文件的id，从小到大排序
+func getDataFileIds(dirPath string) ([]int, error) {
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileIds []int
+
+	for _, entry := range dirEntries {
+		if strings.HasSuffix(entry.Name(), data.DataFileSuffix) {
+			fileId, err := strconv.Atoi(strings.Split(entry.Name(), ".")[0])
+			if err != nil {
+				return nil, ErrDataDirectoryCorrupted
+			}
+			fileIds = append(fileIds, fileId)
+		}
+	}
+
+	// 对文件id排序
+	sort.Ints(fileIds)
+	return fileIds, nil
+}
+
 //创建索引，并设置当前活跃文件offset
 func (db *DB) initIndex() error {
 	// 空数据库
@@ -285,26 +469,7 @@ func (db *DB) initIndex() error {
 		nonMergeFileId = fid
 	}
 
-	updateIndex := func(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) {
-		var oldPos *data.LogRecordPos
-		// 删除类型
-		if typ == data.LogRecordDeleted {
-			oldPos, _ = db.index.Delete(key)
-			db.invalidSize += int64(pos.Size)
-			db.InvalidPiece += 1
-		} else {
-			// 添加到索引中
-			oldPos = db.index.Put(key, pos)
-		}
-
-		if oldPos != nil {
-			db.invalidSize += int64(oldPos.Size)
-			db.InvalidPiece += 1
-		}
-	}
-
 	tracnsactionRecords := make(map[uint64][]*data.TransactionRecord)
-	var currentSeqNo uint64 = nonTxnSeqNo
 
 	// 遍历文件id
 	for i, fid := range db.fileIds {
@@ -321,111 +486,259 @@ func (db *DB) initIndex() error {
 			dataFile = db.olderFiles[fileId]
 		}
 
-		var offset int64 = 0
-		for {
-			// 读取logrecord(每一条数据)
-			logRecord, size, err := dataFile.Read(offset)
-			if err != nil {
-				if err == io.EOF { // 数据读完了，正常错误
-					break
-				}
+		offset, err := db.loadIndexFromDataFile(dataFile, 0, tracnsactionRecords)
+		//如果是活跃文件，末尾可能有没有写完的数据，就更新这个文件的writeoff
+		if i == len(db.fileIds)-1 {
+			if offset, err = db.recoverActiveFile(offset, err); err != nil {
 				return err
 			}
+			db.activeFile.WriteOffset = offset
+		}
+		if err != nil {
+			return err
+		}
+	}
 
-			// 创建索引数据
-			logRecordPos := &data.LogRecordPos{
-				Fid:    fileId,
-				Offset: offset,
-				Size:   uint32(size),
+	// 只读模式下，事务可能还在写入，留到Refresh时继续处理
+	if db.config.ReadOnly {
+		db.pendingTxnRecords = tracnsactionRecords
+		return nil
+	}
+
+	// 记录无效事务的数量
+	for _,v := range tracnsactionRecords{
+		db.InvalidPiece += int64(len(v))
+		for _, r := range v{
+			db.invalidSize += int64(r.Pos.Size)
+		}
+	}
+	return nil
+}
+
+// 从offset开始读取数据文件中的logrecord并更新索引，返回读到的位置
+// 出错时也返回最后一条完整数据的结束位置
+func (db *DB) loadIndexFromDataFile(dataFile *data.DataFile, offset int64, tracnsactionRecords map[uint64][]*data.TransactionRecord) (int64, error) {
+	for {
+		// 读取logrecord(每一条数据)
+		logRecord, size, err := dataFile.Read(offset)
+		if err != nil {
+			if err == io.EOF { // 数据读完了，正常错误
+				break
 			}
+			return offset, err
+		}
+
+		// 创建索引数据
+		logRecordPos := &data.LogRecordPos{
+			Fid:    dataFile.FileId,
+			Offset: offset,
+			Size:   uint32(size),
+			Expire: logRecord.Expire,
+		}
 
-			// 解析事务key
-			key, seqNo := parseSeqLogRecordKey(logRecord.Key)
+		// 解析事务key
+		key, seqNo := parseSeqLogRecordKey(logRecord.Key)
 
-			// 不是事务提交的
-			if seqNo == nonTxnSeqNo {
-				updateIndex(key, logRecord.Type, logRecordPos)
-			} else {
-				// 读取到了事务完成的数据
-				if logRecord.Type == data.LogRecordTxnFinished {
-					//遍历tracnsactionRecords中当前的seqNo，所以即使seqno1失败了，遍历到seqno2时，读取到了LogRecordTxnFinished，也只会遍历seqno2
-					for _, txnRecord := range tracnsactionRecords[seqNo] {
-						updateIndex(txnRecord.Record.Key, txnRecord.Record.Type, txnRecord.Pos)
-					}
-
-					delete(tracnsactionRecords, seqNo)
-				} else {
-					logRecord.Key = key
-					// 放进tracnsactionRecords中
-					tracnsactionRecords[seqNo] = append(tracnsactionRecords[seqNo], &data.TransactionRecord{
-						Record: logRecord,
-						Pos:    logRecordPos,
-					})
+		// 不是事务提交的
+		if seqNo == nonTxnSeqNo {
+			db.updateIndex(key, logRecord.Type, logRecordPos)
+		} else {
+			// 读取到了事务完成的数据
+			if logRecord.Type == data.LogRecordTxnFinished {
+				//遍历tracnsactionRecords中当前的seqNo，所以即使seqno1失败了，遍历到seqno2时，读取到了LogRecordTxnFinished，也只会遍历seqno2
+				for _, txnRecord := range tracnsactionRecords[seqNo] {
+					db.updateIndex(txnRecord.Record.Key, txnRecord.Record.Type, txnRecord.Pos)
 				}
-			}
 
-			//更新序列号
-			if seqNo > currentSeqNo {
-				currentSeqNo = seqNo
+				delete(tracnsactionRecords, seqNo)
+			} else {
+				logRecord.Key = key
+				// 放进tracnsactionRecords中
+				tracnsactionRecords[seqNo] = append(tracnsactionRecords[seqNo], &data.TransactionRecord{
+					Record: logRecord,
+					Pos:    logRecordPos,
+				})
 			}
-			// 更新offset
-			offset += size
 		}
 
-		//如果是活跃文件，就更新这个文件的writeoff
-		if i == len(db.fileIds)-1 {
-			db.activeFile.WriteOffset = offset
+		//更新序列号
+		if seqNo > db.seqNo {
+			db.seqNo = seqNo
 		}
+		// 更新offset
+		offset += size
 	}
+	return offset, nil
+}
 
-	// 记录无效事务的数量
-	for _,v := range tracnsactionRecords{
-		db.InvalidPiece += int64(len(v))
-		for _, r := range v{
-			db.invalidSize += int64(r.Pos.Size)
-		}
+// 根据logrecord的类型更新索引，并统计无效数据
+func (db *DB) updateIndex(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) {
+	var oldPos *data.LogRecordPos
+	// 删除类型，已经过期的数据也当作删除处理
+	if typ == data.LogRecordDeleted || pos.IsExpired() {
+		oldPos, _ = db.index.Delete(key)
+		db.invalidSize += int64(pos.Size)
+		db.InvalidPiece += 1
+	} else {
+		// 添加到索引中
+		oldPos = db.index.Put(key, pos)
+	}
+
+	if oldPos != nil {
+		db.invalidSize += int64(oldPos.Size)
+		db.InvalidPiece += 1
+	}
+}
+
+// 只读模式下读取其他进程在活跃文件和新的数据文件中追加的数据
+func (db *DB) Refresh() error {
+	if !db.config.ReadOnly {
+		return nil
 	}
 
-	db.seqNo = currentSeqNo
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	fileIds, err := getDataFileIds(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+
+	for i, fid := range fileIds {
+		fileId := uint32(fid)
+		// 旧的数据文件不会再有新的数据
+		if db.activeFile != nil && fileId < db.activeFile.FileId {
+			continue
+		}
+
+		// 写入的进程打开了新的活跃文件
+		if db.activeFile == nil || fileId > db.activeFile.FileId {
+			dataFile, err := data.OpenDataFile(db.config.DirPath, fileId, fio.StandardFIO)
+			if err != nil {
+				return err
+			}
+			if db.activeFile != nil {
+				db.olderFiles[db.activeFile.FileId] = db.activeFile
+			}
+			db.activeFile = dataFile
+		}
+
+		offset, err := db.loadIndexFromDataFile(db.activeFile, db.activeFile.WriteOffset, db.pendingTxnRecords)
+		db.activeFile.WriteOffset = offset
+		if err != nil {
+			// 最后一条数据可能还没有写完，下次Refresh时再读取
+			if i == len(fileIds)-1 && err == data.ErrInvalidCRC {
+				break
+			}
+			return err
+		}
+	}
 	return nil
 }
 
+// 读取关闭时记录的事务序列号
+func (db *DB) loadSeqNo() error {
+	fileName := filepath.Join(db.config.DirPath, data.SeqNoFileName)
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return nil
+	}
+
+	seqNoFile, err := data.OpenSeqNoFile(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+	record, _, err := seqNoFile.Read(0)
+	if err != nil {
+		return err
+	}
+	seqNo, err := strconv.ParseUint(string(record.Value), 10, 64)
+	if err != nil {
+		return err
+	}
+	db.seqNo = seqNo
+
+	if err := seqNoFile.Close(); err != nil {
+		return err
+	}
+	// 读取之后删除，防止异常退出后读到旧的序列号
+	return os.Remove(fileName)
+}
+
+// 关闭时记录事务序列号
+func (db *DB) saveSeqNo() error {
+	return writeSeqNoFile(db.config.DirPath, db.seqNo)
+}
+
+func writeSeqNoFile(dirPath string, seqNo uint64) error {
+	seqNoFile, err := data.OpenSeqNoFile(dirPath)
+	if err != nil {
+		return err
+	}
+	record := &data.LogRecord{
+		Key:   []byte(data.SeqNoFileName),
+		Value: []byte(strconv.FormatUint(seqNo, 10)),
+	}
+	encRecord, _ := data.EncodeLogRecord(record)
+	if err := seqNoFile.Write(encRecord); err != nil {
+		return err
+	}
+	if err := seqNoFile.Sync(); err != nil {
+		return err
+	}
+	return seqNoFile.Close()
+}
+
 //删除 添加一条logrecord
 func (db *DB) Delete(key []byte) error {
+	if db.config.ReadOnly {
+		return ErrReadOnly
+	}
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
 
+	db.mu.Lock()
+
 	// 先查询key是否存在 key不存在就直接跳过
 	if pos := db.index.Get(key); pos == nil {
+		db.mu.Unlock()
 		return nil
 	}
 
+	seqNo, err := db.nextNonTxnSeqNo()
+	if err != nil {
+		db.mu.Unlock()
+		return err
+	}
+
 	// 添加logrecord，类型为delete
 	logRecord := &data.LogRecord{
 		Key:  createLogRecordKeyWithSeq(key, nonTxnSeqNo),
 		Type: data.LogRecordDeleted,
 	}
 
-	pos, err := db.appendLogRecordWithLock(logRecord)
+	pos, err := db.appendLogRecord(logRecord)
 
 	if err != nil {
+		db.mu.Unlock()
 		return err
 	}
 	db.invalidSize += int64(pos.Size)
 	db.InvalidPiece += 1
-	//从内存索引中删除
-	oldItem, ok := db.index.Delete(key)
+	//从内存索引中删除，组提交时持久化之后再删除
+	//前面排队的删除可能已经删除了这个key，这时不需要再统计无效数据
+	ticket := db.syncTicket(db.config.SyncWrites, [][]byte{key}, func() {
+		if oldItem, _ := db.index.Delete(key); oldItem != nil {
+			db.invalidSize += int64(oldItem.Size)
+			db.InvalidPiece += 1
+		}
 
-	if !ok {
-		return ErrIndexUpdateFailed
-	}
+		db.markKeyModified(key, seqNo)
+		db.notifyWatchers(seqNo, []*EventEntry{{Type: EventDelete, Key: key}})
+	})
+	db.mu.Unlock()
 
-	if oldItem != nil {
-		db.invalidSize += int64(oldItem.Size)
-		db.InvalidPiece += 1
-	}
-	return nil
+	return db.waitForSync(ticket)
 }
 
 func (db *DB) Sync() error {
@@ -440,16 +753,20 @@ func (db *DB) Sync() error {
 func (db *DB) ListKeys() [][]byte {
 	iterator := db.index.Iterator(false)
 	defer iterator.Close()
-	keys := make([][]byte, db.index.Size())
-	var idx int
+	keys := make([][]byte, 0, db.index.Size())
 	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
-		keys[idx] = iterator.Key()
-		idx++
+		// 跳过已经过期的key
+		if iterator.Value().IsExpired() {
+			continue
+		}
+		keys = append(keys, iterator.Key())
 	}
 	return keys
 }
 
 // 统计db信息
+// KeyNum直接读取索引的大小，不遍历索引，过期的key也计算在内
+// 需要不包含过期key的数量时用迭代器遍历，迭代器和ListKeys都会跳过过期的key
 func (db *DB) Stat() *Stat {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
@@ -460,22 +777,52 @@ func (db *DB) Stat() *Stat {
 		dataFileNum += 1
 	}
 
-	return &Stat{
+	stat := &Stat{
 		KeyNum:      uint(db.index.Size()),
 		DataFileNum: dataFileNum,
 		InvalidSize: db.invalidSize,
 		InvalidPiece: db.InvalidPiece,
 	}
+	if db.valueCache != nil {
+		stat.CacheHits = atomic.LoadUint64(&db.valueCache.hits)
+		stat.CacheMisses = atomic.LoadUint64(&db.valueCache.misses)
+	}
+	return stat
 }
 
 func (db *DB) Close() error{
+	// 后台持久化需要获取db.mu，先在锁外停止
+	db.stopBackgroundSync()
+
 	db.mu.Lock()
 	defer func() {
+		// 释放文件锁
+		if db.fileLock != nil {
+			_ = db.fileLock.Unlock()
+		}
 		db.mu.Unlock()
 	}()
+
+	// 关闭所有watcher的channel
+	for w := range db.watchers {
+		w.close()
+	}
+	db.watchers = make(map[*watcher]struct{})
 	
-	db.index = nil
-	
+	// 关闭索引，b+树索引需要关闭索引文件并记录序列号
+	if db.index != nil {
+		if err := db.index.Close(); err != nil {
+			return err
+		}
+		db.index = nil
+
+		if db.config.IndexType == BPlusTree {
+			if err := db.saveSeqNo(); err != nil {
+				return err
+			}
+		}
+	}
+
 	if db.activeFile == nil {
 		return nil
 	}
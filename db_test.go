@@ -1,9 +1,12 @@
 package kv_go
 
 import (
+	"kv-go/index"
 	"kv-go/utils"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -218,4 +221,261 @@ func TestDB_Stat(t *testing.T) {
 	stat := db.Stat()
 	assert.Equal(t,stat.InvalidPiece,int64(4800))
 	assert.NotNil(t, stat)
-}
\ No newline at end of file
+}
+func TestDB_PutWithTTL(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-ttl")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	// 1.未过期时可以正常读取
+	err = db.PutWithTTL(utils.GetTestKey(1), []byte("hello"), time.Hour)
+	assert.Nil(t, err)
+	val1, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), val1)
+
+	// 2.过期后读取不到
+	err = db.PutWithTTL(utils.GetTestKey(2), []byte("world"), 50*time.Millisecond)
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = db.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 3.迭代器和ListKeys跳过过期的key
+	iter := db.NewIterator(DefaultIteratorConfig)
+	var keys [][]byte
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	assert.Equal(t, [][]byte{utils.GetTestKey(1)}, keys)
+	assert.Equal(t, 1, len(db.ListKeys()))
+	// Stat.KeyNum是索引的大小，包含过期的key
+	assert.Equal(t, uint(2), db.Stat().KeyNum)
+
+	// 4.重新Put之后不再过期
+	err = db.Put(utils.GetTestKey(2), []byte("world"))
+	assert.Nil(t, err)
+	val2, err := db.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("world"), val2)
+
+	// 5.重启后过期时间仍然有效
+	err = db.PutWithTTL(utils.GetTestKey(3), []byte("expired"), 50*time.Millisecond)
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	_, err = db2.Get(utils.GetTestKey(3))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val3, err := db2.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), val3)
+	_ = db2.Close()
+}
+
+func TestDB_BPlusTreeIndex(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(utils.GetTestKey(0))
+	assert.Nil(t, err)
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put(utils.GetTestKey(100), utils.GetTestKey(100))
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	// 反向迭代
+	iterOpts := DefaultIteratorConfig
+	iterOpts.Reverse = true
+	iter := db.NewIterator(iterOpts)
+	iter.Seek(utils.GetTestKey(50))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, utils.GetTestKey(50), iter.Key())
+	iter.Next()
+	assert.Equal(t, utils.GetTestKey(49), iter.Key())
+	iter.Close()
+
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 重启后索引从b+树文件中读取
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), db2.seqNo)
+	assert.Equal(t, uint(100), db2.Stat().KeyNum)
+	_, err = db2.Get(utils.GetTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := db2.Get(utils.GetTestKey(100))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(100), val)
+
+	// 重启后继续写入
+	err = db2.Put(utils.GetTestKey(101), []byte("new value"))
+	assert.Nil(t, err)
+	val, err = db2.Get(utils.GetTestKey(101))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new value"), val)
+	_ = db2.Close()
+}
+
+func TestDB_BPlusTreeIndexOpenError(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-open")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+
+	// 索引文件被锁住时返回错误
+	bpt, err := index.NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+	assert.Nil(t, bpt.Close())
+
+	// 打开失败时释放了文件锁，可以重新打开
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	// 索引文件损坏时返回错误
+	err = os.WriteFile(filepath.Join(dir, index.BPlusTreeIndexFileName), []byte("not a bbolt file"), 0644)
+	assert.Nil(t, err)
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+}
+
+func TestDB_OpenMMap(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-mmap")
+	opts.DirPath = dir
+	opts.DataFileSize = 1024 * 1024
+	opts.MMapAtStartup = false
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 20000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 使用mmap重启
+	opts.MMapAtStartup = true
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(20000), db2.Stat().KeyNum)
+
+	// 启动之后切换回标准文件io，可以继续写入
+	err = db2.Put(utils.GetTestKey(20000), []byte("after mmap"))
+	assert.Nil(t, err)
+	val, err := db2.Get(utils.GetTestKey(20000))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("after mmap"), val)
+	val, err = db2.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.NotNil(t, val)
+	_ = db2.Close()
+}
+
+func TestDB_FileLock(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-flock")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	// 目录已经被打开
+	_, err = Open(opts)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+
+	// 关闭之后可以重新打开
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, db2)
+	err = db2.Close()
+	assert.Nil(t, err)
+}
+
+func TestDB_ReadOnly(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-readonly")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+
+	// 写入的进程还在运行时只读打开
+	roOpts := opts
+	roOpts.ReadOnly = true
+	roDB, err := Open(roOpts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(100), roDB.Stat().KeyNum)
+
+	// 不能写入
+	assert.Equal(t, ErrReadOnly, roDB.Put(utils.GetTestKey(1), []byte("v")))
+	assert.Equal(t, ErrReadOnly, roDB.Delete(utils.GetTestKey(1)))
+	assert.Equal(t, ErrReadOnly, roDB.Merge())
+	wb := roDB.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put(utils.GetTestKey(1), []byte("v"))
+	assert.Equal(t, ErrReadOnly, wb.Commit())
+
+	// 写入的进程继续写入，包括新的数据文件和批量写入
+	for i := 100; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(utils.GetTestKey(0))
+	assert.Nil(t, err)
+	wb2 := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb2.Put(utils.GetTestKey(1), []byte("batch value"))
+	err = wb2.Commit()
+	assert.Nil(t, err)
+
+	_, err = roDB.Get(utils.GetTestKey(500))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	err = roDB.Refresh()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(999), roDB.Stat().KeyNum)
+	_, err = roDB.Get(utils.GetTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := roDB.Get(utils.GetTestKey(500))
+	assert.Nil(t, err)
+	assert.NotNil(t, val)
+	val, err = roDB.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("batch value"), val)
+
+	err = roDB.Close()
+	assert.Nil(t, err)
+}
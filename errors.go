@@ -10,4 +10,13 @@ var (
 	ErrDataDirectoryCorrupted = errors.New("database file is corrupted")
 	ErrExceedMaxBatchNum = errors.New("exceed max batch num")
 	ErrMergeInProcess = errors.New("merge in process")
+	ErrDatabaseIsUsing = errors.New("the database directory is used by another process")
+	ErrReadOnly = errors.New("the database is opened in read only mode")
+	ErrSnapshotReleased = errors.New("the snapshot has been released")
+	ErrTxnConflict = errors.New("transaction conflict, keys read by the transaction were modified")
+	ErrTxnFinished = errors.New("the transaction has been committed or discarded")
+	ErrRepairDirExists = errors.New("the directory to keep the corrupted data already exists")
+	ErrBackupDirNotEmpty = errors.New("the backup directory is not empty")
+	ErrInvalidBackupChain = errors.New("the backups are not a full backup followed by its incremental backups")
+	ErrInvalidBatchSize = errors.New("import batch size must be greater than 0")
 )
\ No newline at end of file
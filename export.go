@@ -0,0 +1,114 @@
+package kv_go
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kv-go/data"
+)
+
+// 导出和导入
+// 格式和数据文件无关，可以在不同版本的数据文件格式之间迁移数据，也可以用jq等工具查看
+// 每行一个json对象，key和value使用base64编码，expire是unix纳秒时间戳，永不过期时省略：
+//   {"key":"a2V5LTE=","value":"dmFsdWUtMQ==","expire":1760000000000000000}
+// 导出时按key的顺序写入，不包括已经删除和已经过期的数据
+
+type exportRecord struct {
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+	Expire int64  `json:"expire,omitempty"`
+}
+
+// 导出所有数据到w，返回导出的数据条数
+// 从快照中读取，导出的是调用时的数据，导出期间的写入不会被导出
+func (db *DB) Export(w io.Writer) (int, error) {
+	snapshot := db.Snapshot()
+	defer snapshot.Release()
+
+	iter := snapshot.NewIterator(DefaultIteratorConfig)
+	defer iter.Close()
+
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	count := 0
+	for ; iter.Valid(); iter.Next() {
+		value, err := iter.Value()
+		// 迭代时刚好过期的数据
+		if err == ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		record := &exportRecord{
+			Key:    iter.Key(),
+			Value:  value,
+			Expire: iter.indexIter.Value().Expire,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, writer.Flush()
+}
+
+// 使用DefaultImportConfig导入Export导出的数据，返回导入的数据条数
+func (db *DB) Import(r io.Reader) (int, error) {
+	return db.ImportWithConfig(r, DefaultImportConfig)
+}
+
+// 每BatchSize个key用一个WriteBatch写入，出错时之前已经提交的WriteBatch不会回滚
+// 已经存在的key会被覆盖，已经过期的数据不导入
+// 返回写入的key数量，同一个WriteBatch中重复的key只计算一次
+func (db *DB) ImportWithConfig(r io.Reader, config ImportConfig) (int, error) {
+	if db.config.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	if config.BatchSize == 0 {
+		return 0, ErrInvalidBatchSize
+	}
+
+	wbConfig := WriteBatchConfig{MaxBatchNum: config.BatchSize, SyncWrites: config.SyncWrites}
+	wb := db.NewWriteBatch(wbConfig)
+	// n是读取到的数据条数，用于报告出错的位置
+	n, count := 0, 0
+	commit := func() error {
+		pending := len(wb.pendingWrites)
+		if err := wb.Commit(); err != nil {
+			return err
+		}
+		count += pending
+		return nil
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		record := &exportRecord{}
+		n++
+		if err := decoder.Decode(record); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return count, fmt.Errorf("import record %d: %w", n, err)
+		}
+		if data.IsExpired(record.Expire) {
+			continue
+		}
+		if err := wb.putWithExpire(record.Key, record.Value, record.Expire); err != nil {
+			return count, fmt.Errorf("import record %d: %w", n, err)
+		}
+
+		if uint(len(wb.pendingWrites)) >= config.BatchSize {
+			if err := commit(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	if err := commit(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
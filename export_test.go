@@ -0,0 +1,85 @@
+package kv_go
+
+import (
+	"bytes"
+	"encoding/json"
+	"kv-go/utils"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_ExportImport(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-export")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.RandomValue(64)))
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	assert.Nil(t, db.PutWithTTL([]byte("ttl-key"), []byte("ttl-value"), time.Hour))
+	assert.Nil(t, db.PutWithTTL([]byte("expired-key"), []byte("expired-value"), time.Millisecond))
+	time.Sleep(time.Millisecond * 5)
+
+	buf := new(bytes.Buffer)
+	n, err := db.Export(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 901, n)
+
+	// 每行一个json对象，key和value是base64编码
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 901, len(lines))
+	record := &exportRecord{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), record))
+	assert.Equal(t, utils.GetTestKey(100), record.Key)
+	assert.Equal(t, int64(0), record.Expire)
+
+	opts2 := DefaultConfig
+	dir2, _ := os.MkdirTemp("", "bitcask-go-import")
+	opts2.DirPath = dir2
+	db2, err := Open(opts2)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+
+	config := DefaultImportConfig
+	config.BatchSize = 100
+	n, err = db2.ImportWithConfig(bytes.NewReader(buf.Bytes()), config)
+	assert.Nil(t, err)
+	assert.Equal(t, 901, n)
+	assert.Equal(t, 901, len(db2.ListKeys()))
+	for i := 100; i < 1000; i++ {
+		val1, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		val2, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, val1, val2)
+	}
+	// 过期时间保持不变
+	pos1, pos2 := db.index.Get([]byte("ttl-key")), db2.index.Get([]byte("ttl-key"))
+	assert.Equal(t, pos1.Expire, pos2.Expire)
+	_, err = db2.Get([]byte("expired-key"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 格式错误时返回出错的位置
+	_, err = db2.Import(strings.NewReader(lines[0] + "\n{bad json}\n"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "record 2")
+
+	// 同一个WriteBatch中重复的key只计算一次
+	n, err = db2.Import(strings.NewReader(lines[0] + "\n" + lines[0] + "\n" + lines[1] + "\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	config.BatchSize = 0
+	_, err = db2.ImportWithConfig(strings.NewReader(lines[0]), config)
+	assert.Equal(t, ErrInvalidBatchSize, err)
+}
@@ -33,6 +33,13 @@ func (fio *FileIO) Size() (int64,error){
 	}
 	return stat.Size(),nil
 }
-func NewIoManager(fileName string)(IOManager,error){
-	return NewFileIOManager(fileName)
+func NewIoManager(fileName string, ioType FileIOType)(IOManager,error){
+	switch ioType {
+	case StandardFIO:
+		return NewFileIOManager(fileName)
+	case MemoryMap:
+		return NewMMapIOManager(fileName)
+	default:
+		panic("unsupported io type")
+	}
 }
\ No newline at end of file
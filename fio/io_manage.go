@@ -6,6 +6,16 @@ const(
 	DataFilePerm = 0644
 )
 
+type FileIOType = byte
+
+const (
+	// 标准文件io
+	StandardFIO FileIOType = iota
+
+	// 内存映射，只读
+	MemoryMap
+)
+
 func NewFileIOManager(fileName string) (*FileIO,error){
 	// 文件不存在就创建文件
 	fd,err := os.OpenFile(fileName, os.O_CREATE | os.O_RDWR | os.O_APPEND,DataFilePerm)
@@ -0,0 +1,52 @@
+package fio
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/exp/mmap"
+)
+
+var ErrMMapWriteNotSupported = errors.New("mmap io manager is read only")
+
+// 内存映射io，只用于启动时读取数据文件构建索引
+type MMap struct {
+	readerAt *mmap.ReaderAt
+}
+
+func NewMMapIOManager(fileName string) (*MMap, error) {
+	// 文件不存在就创建文件
+	fd, err := os.OpenFile(fileName, os.O_CREATE, DataFilePerm)
+	if err != nil {
+		return nil, err
+	}
+	if err := fd.Close(); err != nil {
+		return nil, err
+	}
+
+	readerAt, err := mmap.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &MMap{readerAt: readerAt}, nil
+}
+
+func (mm *MMap) Read(b []byte, offset int64) (int, error) {
+	return mm.readerAt.ReadAt(b, offset)
+}
+
+func (mm *MMap) Write([]byte) (int, error) {
+	return 0, ErrMMapWriteNotSupported
+}
+
+func (mm *MMap) Sync() error {
+	return ErrMMapWriteNotSupported
+}
+
+func (mm *MMap) Close() error {
+	return mm.readerAt.Close()
+}
+
+func (mm *MMap) Size() (int64, error) {
+	return int64(mm.readerAt.Len()), nil
+}
@@ -0,0 +1,151 @@
+package kv_go
+
+import "sync"
+
+// 组提交
+// SyncWrites时每次写入都要Sync，并且是在db.mu中进行的，并发写入时只能一个一个地Sync
+// 开启GroupCommit之后，写入在db.mu中只追加到数据文件，释放锁之后再等待Sync
+// 等待的写入中第一个成为leader，Sync一次之后，在这之前追加的所有数据都持久化了，其他写入直接返回
+// 追加之后的索引更新和watcher通知按照写入顺序排队，持久化之后由leader在db.mu中执行，数据持久化之前读取不到
+// Sync失败时这些写入不会更新到索引中，和不开启组提交时一样返回错误
+
+type groupCommitter struct {
+	mu      *sync.Mutex
+	cond    *sync.Cond
+	written uint64 // 最后一次追加到数据文件的写入编号
+	synced  uint64 // 已经持久化的写入编号
+	syncing bool   // 是否有leader正在Sync
+	syncNum uint64 // Sync的次数
+
+	pending []*pendingCommit // 已经追加到数据文件还没有更新索引的写入，按照写入编号排序
+	failed  map[uint64]error // Sync失败的写入编号，等待的写入取走错误之后删除
+}
+
+type pendingCommit struct {
+	ticket uint64
+	keys   [][]byte
+	apply  func() // 更新索引和通知watcher，调用时需要持有db.mu
+}
+
+func newGroupCommitter() *groupCommitter {
+	mu := new(sync.Mutex)
+	return &groupCommitter{
+		mu:     mu,
+		cond:   sync.NewCond(mu),
+		failed: make(map[uint64]error),
+	}
+}
+
+// 数据追加到数据文件之后获取一个写入编号，需要持久化时在释放db.mu之后调用waitForSync
+// apply是更新索引和通知watcher，不需要等待时直接执行，否则排队等持久化之后再执行
+// 前面还有排队的写入时，不需要持久化的写入也要排队，保证按照写入顺序更新索引
+// 调用时需要持有db.mu，编号的顺序和写入数据文件的顺序一致
+// 不需要等待时返回0
+func (db *DB) syncTicket(syncWrites bool, keys [][]byte, apply func()) uint64 {
+	if db.groupCommitter == nil {
+		apply()
+		return 0
+	}
+	gc := db.groupCommitter
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if !syncWrites && len(gc.pending) == 0 {
+		apply()
+		return 0
+	}
+	gc.written += 1
+	gc.pending = append(gc.pending, &pendingCommit{ticket: gc.written, keys: keys, apply: apply})
+	return gc.written
+}
+
+// 已经追加到数据文件但还没有更新到索引中的key，调用时需要持有db.mu
+func (db *DB) unappliedKeys() map[string]struct{} {
+	if db.groupCommitter == nil {
+		return nil
+	}
+	gc := db.groupCommitter
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if len(gc.pending) == 0 {
+		return nil
+	}
+	keys := make(map[string]struct{})
+	for _, commit := range gc.pending {
+		for _, key := range commit.keys {
+			keys[string(key)] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// 等待写入编号ticket之前的数据都持久化并更新到索引中，不能持有db.mu
+func (db *DB) waitForSync(ticket uint64) error {
+	if ticket == 0 {
+		return nil
+	}
+	gc := db.groupCommitter
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	for {
+		if err, ok := gc.failed[ticket]; ok {
+			delete(gc.failed, ticket)
+			return err
+		}
+		if gc.synced >= ticket {
+			return nil
+		}
+		if !gc.syncing {
+			db.leadSync()
+			continue
+		}
+		gc.cond.Wait()
+	}
+}
+
+// 成为leader，把已经追加的数据一起持久化，然后按顺序更新索引
+// 调用时需要持有gc.mu，Sync和更新索引时会释放
+func (db *DB) leadSync() {
+	gc := db.groupCommitter
+	gc.syncing = true
+	target := gc.written
+	gc.mu.Unlock()
+
+	// 切换活跃文件时旧的文件已经Sync过了，只需要Sync当前的活跃文件
+	db.mu.RLock()
+	activeFile := db.activeFile
+	db.mu.RUnlock()
+	var err error
+	if activeFile != nil {
+		err = activeFile.Sync()
+	}
+
+	// 取出target之前的写入，Sync成功时更新索引，失败时丢弃
+	db.mu.Lock()
+	gc.mu.Lock()
+	n := 0
+	for n < len(gc.pending) && gc.pending[n].ticket <= target {
+		n++
+	}
+	commits := gc.pending[:n]
+	gc.pending = gc.pending[n:]
+	gc.mu.Unlock()
+	for _, commit := range commits {
+		if err == nil {
+			commit.apply()
+		}
+	}
+	db.mu.Unlock()
+
+	gc.mu.Lock()
+	gc.syncing = false
+	gc.syncNum += 1
+	if err == nil {
+		gc.synced = target
+	} else {
+		for _, commit := range commits {
+			gc.failed[commit.ticket] = err
+		}
+	}
+	gc.cond.Broadcast()
+}
@@ -0,0 +1,207 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/utils"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_GroupCommit(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-group-commit")
+	opts.DirPath = dir
+	opts.SyncWrites = true
+	opts.GroupCommit = true
+	opts.DataFileSize = 64 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	// 并发写入，utils.RandomValue不能并发调用
+	value := utils.RandomValue(128)
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := utils.GetTestKey(g*100 + i)
+				switch i % 3 {
+				case 0:
+					assert.Nil(t, db.Put(key, value))
+				case 1:
+					wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+					assert.Nil(t, wb.Put(key, value))
+					assert.Nil(t, wb.Commit())
+				case 2:
+					txn := db.Begin()
+					assert.Nil(t, txn.Put(key, value))
+					assert.Nil(t, txn.Commit())
+				}
+			}
+			// 删除一部分
+			assert.Nil(t, db.Delete(utils.GetTestKey(g*100)))
+		}(g)
+	}
+	wg.Wait()
+
+	// 所有写入都持久化了，并发写入是否共用Sync取决于时序
+	gc := db.groupCommitter
+	assert.Equal(t, gc.written, gc.synced)
+	assert.LessOrEqual(t, gc.syncNum, gc.written)
+	assert.Equal(t, 20*49, len(db.ListKeys()))
+
+	// 重启之后数据都在
+	err = db.Close()
+	assert.Nil(t, err)
+	db, err = Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 20*49, len(db.ListKeys()))
+	for g := 0; g < 20; g++ {
+		_, err := db.Get(utils.GetTestKey(g * 100))
+		assert.Equal(t, ErrKeyNotFound, err)
+		_, err = db.Get(utils.GetTestKey(g*100 + 49))
+		assert.Nil(t, err)
+	}
+}
+
+func TestDB_GroupCommitShareSync(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-group-commit-share")
+	opts.DirPath = dir
+	opts.SyncWrites = true
+	opts.GroupCommit = true
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 三次写入追加之后还没有等待持久化
+	tickets := make([]uint64, 0, 4)
+	applied := 0
+	db.mu.Lock()
+	for i := 0; i < 3; i++ {
+		_, err := db.appendLogRecord(&data.LogRecord{
+			Key:   createLogRecordKeyWithSeq(utils.GetTestKey(i), nonTxnSeqNo),
+			Value: utils.RandomValue(128),
+		})
+		assert.Nil(t, err)
+		tickets = append(tickets, db.syncTicket(true, nil, func() { applied++ }))
+	}
+	// 前面还有排队的写入，不需要持久化的写入也要排队
+	tickets = append(tickets, db.syncTicket(false, nil, func() { applied++ }))
+	db.mu.Unlock()
+	assert.Equal(t, uint64(4), tickets[3])
+	assert.Equal(t, 0, applied)
+
+	// 最后一次写入的Sync把之前的写入也持久化了
+	gc := db.groupCommitter
+	assert.Nil(t, db.waitForSync(tickets[3]))
+	assert.Equal(t, uint64(1), gc.syncNum)
+	assert.Equal(t, 4, applied)
+	assert.Nil(t, db.waitForSync(tickets[0]))
+	assert.Nil(t, db.waitForSync(tickets[1]))
+	assert.Nil(t, db.waitForSync(tickets[2]))
+	assert.Equal(t, uint64(1), gc.syncNum)
+	assert.Equal(t, tickets[3], gc.synced)
+
+	// 没有排队的写入时直接更新
+	db.mu.Lock()
+	assert.Equal(t, uint64(0), db.syncTicket(false, nil, func() { applied++ }))
+	db.mu.Unlock()
+	assert.Equal(t, 5, applied)
+}
+
+func TestDB_GroupCommitVisibleAfterSync(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-group-commit-visible")
+	opts.DirPath = dir
+	opts.SyncWrites = true
+	opts.GroupCommit = true
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	events, cancel := db.Watch(nil)
+	defer cancel()
+
+	// 模拟正在Sync的leader，写入追加之后等待持久化
+	gc := db.groupCommitter
+	gc.mu.Lock()
+	gc.syncing = true
+	gc.mu.Unlock()
+
+	txn := db.Begin()
+	done := make(chan error)
+	go func() {
+		done <- db.Put(utils.GetTestKey(1), utils.GetTestKey(1))
+	}()
+	for {
+		gc.mu.Lock()
+		written := gc.written
+		gc.mu.Unlock()
+		if written == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 持久化之前读取不到，也没有事件
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	select {
+	case <-events:
+		t.Fatal("event before sync")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// 事务读取到的是旧的数据，提交时冲突
+	_, err = txn.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Nil(t, txn.Put(utils.GetTestKey(2), utils.GetTestKey(2)))
+
+	gc.mu.Lock()
+	gc.syncing = false
+	gc.cond.Broadcast()
+	gc.mu.Unlock()
+	assert.Nil(t, <-done)
+
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(1), val)
+	event := <-events
+	assert.Equal(t, utils.GetTestKey(1), event.Entries[0].Key)
+	assert.Equal(t, ErrTxnConflict, txn.Commit())
+}
+
+func TestDB_GroupCommitSyncFailed(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-group-commit-failed")
+	opts.DirPath = dir
+	opts.SyncWrites = true
+	opts.GroupCommit = true
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	db.mu.Lock()
+	_, err = db.appendLogRecord(&data.LogRecord{
+		Key:   createLogRecordKeyWithSeq(utils.GetTestKey(1), nonTxnSeqNo),
+		Value: utils.RandomValue(128),
+	})
+	assert.Nil(t, err)
+	applied := false
+	ticket := db.syncTicket(true, nil, func() { applied = true })
+	db.mu.Unlock()
+
+	// Sync失败时不更新索引，返回错误
+	assert.Nil(t, db.activeFile.IOManager.Close())
+	assert.NotNil(t, db.waitForSync(ticket))
+	assert.False(t, applied)
+	assert.Equal(t, 0, len(db.groupCommitter.pending))
+	assert.Equal(t, 0, len(db.groupCommitter.failed))
+}
@@ -2,24 +2,34 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	kv_go "kv-go"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
 )
 
+// listkeys每页默认返回的key数量
+const defaultListLimit = 100
+
 var db *kv_go.DB
 
-func init(){
-	var err error
-	options := kv_go.DefaultConfig
-	db,err =kv_go.Open(options)
-	if err != nil {
-		panic(fmt.Sprintf("failed to open db %v", err))
-	}
-}
+var (
+	addr    = flag.String("addr", "localhost:8080", "http listen address")
+	dirPath = flag.String("dir", filepath.Join(os.TempDir(), "kv-go-http"), "data directory of the db")
+)
 
 func handlePut(writer http.ResponseWriter,request *http.Request){
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	var data map[string]string
 
 	if err := json.NewDecoder(request.Body).Decode(&data); err != nil {
@@ -36,8 +46,159 @@ func handlePut(writer http.ResponseWriter,request *http.Request){
 	}
 }
 
+func handleGet(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := request.URL.Query().Get("key")
+	value, err := db.Get([]byte(key))
+	if err != nil {
+		switch err {
+		case kv_go.ErrKeyIsEmpty:
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+		case kv_go.ErrKeyNotFound:
+			http.Error(writer, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			log.Printf("failed to get value in db %v\n", err)
+		}
+		return
+	}
+
+	writeJSON(writer, string(value))
+}
+
+func handleDelete(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := request.URL.Query().Get("key")
+	if err := db.Delete([]byte(key)); err != nil {
+		if err == kv_go.ErrKeyIsEmpty {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		log.Printf("failed to delete value in db %v\n", err)
+		return
+	}
+
+	writeJSON(writer, "OK")
+}
+
+type listKeysResponse struct {
+	Keys []string `json:"keys"`
+	// 下一页的起始key，没有下一页时为空
+	Next string `json:"next,omitempty"`
+}
+
+// GET /kv_go/listkeys?prefix=&start=&limit=
+// start为上一页返回的next，从这个key开始继续遍历
+func handleListKeys(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	limit := defaultListLimit
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(writer, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	config := kv_go.DefaultIteratorConfig
+	config.Prefix = []byte(query.Get("prefix"))
+	iter := db.NewIterator(config)
+	defer iter.Close()
+
+	if start := query.Get("start"); start != "" {
+		iter.Seek([]byte(start))
+	} else {
+		iter.Rewind()
+	}
+
+	resp := listKeysResponse{Keys: []string{}}
+	for ; iter.Valid(); iter.Next() {
+		if len(resp.Keys) == limit {
+			resp.Next = string(iter.Key())
+			break
+		}
+		resp.Keys = append(resp.Keys, string(iter.Key()))
+	}
+
+	writeJSON(writer, resp)
+}
+
+func handleStat(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(writer, db.Stat())
+}
+
+func handleMerge(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := db.Merge(); err != nil {
+		if err == kv_go.ErrMergeInProcess {
+			http.Error(writer, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		log.Printf("failed to merge db %v\n", err)
+		return
+	}
+
+	writeJSON(writer, "OK")
+}
+
+func writeJSON(writer http.ResponseWriter, v interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(v)
+}
+
 func main(){
+	flag.Parse()
+
+	var err error
+	options := kv_go.DefaultConfig
+	options.DirPath = *dirPath
+	db,err =kv_go.Open(options)
+	if err != nil {
+		panic(fmt.Sprintf("failed to open db %v", err))
+	}
+
+	// 退出时关闭db，释放文件锁
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		if err := db.Close(); err != nil {
+			log.Printf("failed to close db %v\n", err)
+		}
+		os.Exit(0)
+	}()
+
 	http.HandleFunc("/kv_go/put",handlePut)
+	http.HandleFunc("/kv_go/get", handleGet)
+	http.HandleFunc("/kv_go/delete", handleDelete)
+	http.HandleFunc("/kv_go/listkeys", handleListKeys)
+	http.HandleFunc("/kv_go/stat", handleStat)
+	http.HandleFunc("/kv_go/merge", handleMerge)
 
-	_ = http.ListenAndServe("localhost:8080",nil)
-}
\ No newline at end of file
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,403 @@
+package kv_go
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"kv-go/data"
+	"kv-go/fio"
+	"kv-go/index"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 增量备份
+// 数据文件只会追加，并且按fileId命名，两次备份之间只有新的数据文件和活跃文件末尾的数据是新写入的
+// 每次备份在目录中写入一个manifest，记录备份时每个文件的大小和校验和，以及这次备份拷贝了哪一部分
+// 下一次备份根据上一次的manifest只拷贝新的文件和文件增长的部分
+// merge会替换旧的数据文件，merge完成文件变化之后重新计算每个文件的校验和，发生变化的文件完整拷贝
+// b+树索引文件不是只追加的，每次备份都拷贝索引的副本
+
+const backupManifestName = "backup-manifest"
+
+type BackupManifest struct {
+	Files []BackupFile `json:"files"` // 备份时数据目录中的所有文件
+}
+
+type BackupFile struct {
+	Name     string `json:"name"`
+	FileId   uint32 `json:"file_id"`  // 数据文件的id，其他文件为0
+	Size     int64  `json:"size"`     // 备份时文件的大小
+	Checksum uint32 `json:"checksum"` // 文件前Size个字节的crc32
+	Copied   bool   `json:"copied"`   // 这次备份是否拷贝了这个文件
+	Offset   int64  `json:"offset"`   // 这次备份只拷贝了[Offset, Size)的部分
+}
+
+func (m *BackupManifest) file(name string) (BackupFile, bool) {
+	for _, f := range m.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return BackupFile{}, false
+}
+
+// 读取备份目录中的manifest，作为下一次增量备份的基础
+func ReadBackupManifest(dir string) (*BackupManifest, error) {
+	buf, err := os.ReadFile(filepath.Join(dir, backupManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(buf, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeBackupManifest(dir string, manifest *BackupManifest) error {
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, backupManifestName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fio.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 备份manifest之后的数据到destDir，manifest为nil时是全量备份，全量备份的目录可以直接用Open打开
+// 返回这次备份的manifest，也会写入destDir，下一次增量备份传入这个manifest
+// 不切换活跃文件，活跃文件只备份到当前写入的位置
+func (db *DB) BackupSince(manifest *BackupManifest, destDir string) (*BackupManifest, error) {
+	if db.config.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if manifest == nil {
+		manifest = &BackupManifest{}
+	}
+	if err := prepareBackupDir(destDir); err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	activeFileId, activeSize := uint32(0), int64(0)
+	if db.activeFile != nil {
+		if err := db.activeFile.Sync(); err != nil {
+			db.mu.Unlock()
+			return nil, err
+		}
+		activeFileId, activeSize = db.activeFile.FileId, db.activeFile.WriteOffset
+	}
+	var indexSnapshot index.Indexer
+	if db.config.IndexType == BPlusTree {
+		indexSnapshot = db.index.Clone()
+	}
+	seqNo := db.seqNo
+	db.mu.Unlock()
+
+	newManifest, err := db.backupFilesSince(manifest, destDir, activeFileId, activeSize)
+	// 序列号上限每次都拷贝，恢复之后分配的序列号也要比已经分配过的大
+	if err == nil {
+		err = backupSeqNoLease(db.config.DirPath, destDir, newManifest)
+	}
+	if err == nil && indexSnapshot != nil {
+		err = writeBackupIndex(destDir, indexSnapshot, seqNo)
+		for _, name := range []string{index.BPlusTreeIndexFileName, data.SeqNoFileName} {
+			if err != nil {
+				break
+			}
+			var f BackupFile
+			f, err = backupFileInfo(destDir, name)
+			f.Copied = true
+			newManifest.Files = append(newManifest.Files, f)
+		}
+	}
+	if indexSnapshot != nil {
+		_ = indexSnapshot.Close()
+	}
+	if err == nil {
+		err = writeBackupManifest(destDir, newManifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newManifest, nil
+}
+
+func (db *DB) backupFilesSince(manifest *BackupManifest, destDir string, activeFileId uint32, activeSize int64) (*BackupManifest, error) {
+	newManifest := &BackupManifest{}
+
+	// merge完成文件变化之后，旧的数据文件可能被替换了
+	mergeFinished, err := backupFileInfo(db.config.DirPath, data.MergeFinishedFileName)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	oldMergeFinished, _ := manifest.file(data.MergeFinishedFileName)
+	mergeChanged := mergeFinished.Size != oldMergeFinished.Size || mergeFinished.Checksum != oldMergeFinished.Checksum
+
+	entries, err := os.ReadDir(db.config.DirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		srcPath := filepath.Join(db.config.DirPath, name)
+
+		if name == data.HintFileName || name == data.MergeFinishedFileName {
+			f, err := backupFileInfo(db.config.DirPath, name)
+			if err != nil {
+				return nil, err
+			}
+			if old, ok := manifest.file(name); !ok || old.Size != f.Size || old.Checksum != f.Checksum {
+				if f, err = copyFileRange(srcPath, filepath.Join(destDir, name), 0, f.Size, 0); err != nil {
+					return nil, err
+				}
+				f.Name = name
+			}
+			newManifest.Files = append(newManifest.Files, f)
+			continue
+		}
+
+		if !strings.HasSuffix(name, data.DataFileSuffix) {
+			continue
+		}
+		fileId, err := strconv.Atoi(strings.TrimSuffix(name, data.DataFileSuffix))
+		if err != nil {
+			return nil, ErrDataDirectoryCorrupted
+		}
+		// 备份开始之后创建的数据文件
+		if uint32(fileId) > activeFileId {
+			continue
+		}
+		var size int64
+		if uint32(fileId) == activeFileId {
+			size = activeSize
+		} else {
+			stat, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			size = stat.Size()
+		}
+
+		f, err := backupDataFile(srcPath, filepath.Join(destDir, name), size, manifest, mergeChanged)
+		if err != nil {
+			return nil, err
+		}
+		f.Name, f.FileId = name, uint32(fileId)
+		newManifest.Files = append(newManifest.Files, f)
+	}
+	return newManifest, nil
+}
+
+func backupSeqNoLease(srcDir, destDir string, manifest *BackupManifest) error {
+	if err := copySeqNoLease(srcDir, destDir); err != nil {
+		return err
+	}
+	f, err := backupFileInfo(destDir, data.SeqNoLeaseFileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.Copied = true
+	manifest.Files = append(manifest.Files, f)
+	return nil
+}
+
+// 根据上一次备份时的大小和校验和，只拷贝新的文件或者文件增长的部分
+func backupDataFile(srcPath, dstPath string, size int64, manifest *BackupManifest, mergeChanged bool) (BackupFile, error) {
+	old, ok := manifest.file(filepath.Base(srcPath))
+	if ok && size >= old.Size {
+		unchanged := true
+		if mergeChanged {
+			checksum, err := fileChecksum(srcPath, old.Size)
+			if err != nil {
+				return BackupFile{}, err
+			}
+			unchanged = checksum == old.Checksum
+		}
+		if unchanged && size == old.Size {
+			return BackupFile{Size: size, Checksum: old.Checksum}, nil
+		}
+		if unchanged {
+			return copyFileRange(srcPath, dstPath, old.Size, size, old.Checksum)
+		}
+	}
+	return copyFileRange(srcPath, dstPath, 0, size, 0)
+}
+
+// 拷贝[offset, size)部分，checksum是前offset个字节的crc32，返回的BackupFile中是整个文件的校验和
+func copyFileRange(srcPath, dstPath string, offset, size int64, checksum uint32) (BackupFile, error) {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fio.DataFilePerm)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	hash := &crc32Writer{crc: checksum}
+	reader := io.NewSectionReader(srcFile, offset, size-offset)
+	if _, err := io.Copy(io.MultiWriter(dstFile, hash), reader); err != nil {
+		_ = dstFile.Close()
+		return BackupFile{}, err
+	}
+	if err := dstFile.Sync(); err != nil {
+		_ = dstFile.Close()
+		return BackupFile{}, err
+	}
+	if err := dstFile.Close(); err != nil {
+		return BackupFile{}, err
+	}
+	return BackupFile{
+		Size:     size,
+		Checksum: hash.crc,
+		Copied:   true,
+		Offset:   offset,
+	}, nil
+}
+
+// 在前面数据的crc32上继续计算写入的数据
+type crc32Writer struct {
+	crc uint32
+}
+
+func (w *crc32Writer) Write(p []byte) (int, error) {
+	w.crc = crc32.Update(w.crc, crc32.IEEETable, p)
+	return len(p), nil
+}
+
+// 文件前size个字节的crc32
+func fileChecksum(filePath string, size int64) (uint32, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	hash := &crc32Writer{}
+	if _, err := io.Copy(hash, io.NewSectionReader(f, 0, size)); err != nil {
+		return 0, err
+	}
+	return hash.crc, nil
+}
+
+func backupFileInfo(dir, name string) (BackupFile, error) {
+	filePath := filepath.Join(dir, name)
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	checksum, err := fileChecksum(filePath, stat.Size())
+	if err != nil {
+		return BackupFile{}, err
+	}
+	return BackupFile{Name: name, Size: stat.Size(), Checksum: checksum}, nil
+}
+
+// 把一个全量备份和之后的增量备份按顺序合并到destDir，destDir不存在时会创建，已经存在时必须是空目录
+// 最后检查每个文件的大小和校验和是否和最后一次备份的manifest一致
+func RestoreBackup(destDir string, backupDirs ...string) error {
+	if len(backupDirs) == 0 {
+		return ErrInvalidBackupChain
+	}
+	if err := prepareBackupDir(destDir); err != nil {
+		return err
+	}
+
+	var manifest *BackupManifest
+	for i, backupDir := range backupDirs {
+		m, err := ReadBackupManifest(backupDir)
+		if err != nil {
+			return err
+		}
+		for _, f := range m.Files {
+			// 第一个备份必须是全量备份
+			if i == 0 && (!f.Copied || f.Offset != 0) {
+				return ErrInvalidBackupChain
+			}
+			if !f.Copied {
+				continue
+			}
+			if err := restoreBackupFile(filepath.Join(backupDir, f.Name), filepath.Join(destDir, f.Name), f.Offset); err != nil {
+				return err
+			}
+		}
+		manifest = m
+	}
+
+	// merge之后被删除的数据文件
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, ok := manifest.file(entry.Name()); !ok {
+			if err := os.Remove(filepath.Join(destDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range manifest.Files {
+		restored, err := backupFileInfo(destDir, f.Name)
+		if err != nil {
+			return err
+		}
+		if restored.Size != f.Size || restored.Checksum != f.Checksum {
+			return ErrInvalidBackupChain
+		}
+	}
+	return nil
+}
+
+// offset为0时是完整的文件，否则追加到已经恢复的文件末尾
+func restoreBackupFile(srcPath, dstPath string, offset int64) error {
+	if offset == 0 {
+		return copyFile(srcPath, dstPath)
+	}
+	stat, err := os.Stat(dstPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrInvalidBackupChain
+		}
+		return err
+	}
+	if stat.Size() != offset {
+		return ErrInvalidBackupChain
+	}
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	dstFile, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_APPEND, fio.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+	if err := dstFile.Sync(); err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
@@ -0,0 +1,211 @@
+package kv_go
+
+import (
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func putTestKeys(t *testing.T, db *DB, start, end int) {
+	for i := start; i < end; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+}
+
+func newTestBackupDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "bitcask-go-incremental-backup")
+	assert.Nil(t, err)
+	return dir
+}
+
+func TestDB_BackupSince(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup-since")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	backupDirs := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		backupDirs = append(backupDirs, newTestBackupDir(t))
+		defer os.RemoveAll(backupDirs[i])
+	}
+
+	putTestKeys(t, db, 0, 1000)
+	full, err := db.BackupSince(nil, backupDirs[0])
+	assert.Nil(t, err)
+	for _, f := range full.Files {
+		assert.True(t, f.Copied)
+		assert.Equal(t, int64(0), f.Offset)
+	}
+
+	// 旧的数据文件不需要再拷贝，活跃文件只拷贝增长的部分
+	putTestKeys(t, db, 1000, 1100)
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	inc1, err := db.BackupSince(full, backupDirs[1])
+	assert.Nil(t, err)
+	var copied, grown int
+	for _, f := range inc1.Files {
+		if f.Copied {
+			copied++
+		}
+		if f.Offset > 0 {
+			grown++
+		}
+	}
+	assert.True(t, copied < len(inc1.Files))
+	assert.Equal(t, 1, grown)
+
+	putTestKeys(t, db, 1100, 1200)
+	inc2, err := db.BackupSince(inc1, backupDirs[2])
+	assert.Nil(t, err)
+
+	// merge替换了旧的数据文件
+	assert.Nil(t, db.Merge())
+	putTestKeys(t, db, 1200, 1300)
+	manifest, err := ReadBackupManifest(backupDirs[2])
+	assert.Nil(t, err)
+	assert.Equal(t, inc2, manifest)
+	_, err = db.BackupSince(manifest, backupDirs[3])
+	assert.Nil(t, err)
+
+	restoreDir := newTestBackupDir(t)
+	defer os.RemoveAll(restoreDir)
+	err = RestoreBackup(restoreDir, backupDirs...)
+	assert.Nil(t, err)
+
+	restoreOpts := opts
+	restoreOpts.DirPath = restoreDir
+	restoreDB, err := Open(restoreOpts)
+	assert.Nil(t, err)
+	defer restoreDB.Close()
+	assert.Equal(t, 1250, len(restoreDB.ListKeys()))
+	for i := 50; i < 1300; i++ {
+		val, err := restoreDB.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+}
+
+func TestRestoreBackup_InvalidChain(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-restore-invalid")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	backupDirs := make([]string, 0, 3)
+	var manifest *BackupManifest
+	for i := 0; i < 3; i++ {
+		backupDirs = append(backupDirs, newTestBackupDir(t))
+		defer os.RemoveAll(backupDirs[i])
+		putTestKeys(t, db, i*100, (i+1)*100)
+		manifest, err = db.BackupSince(manifest, backupDirs[i])
+		assert.Nil(t, err)
+	}
+
+	restoreDir := newTestBackupDir(t)
+	defer os.RemoveAll(restoreDir)
+	// 第一个不是全量备份
+	err = RestoreBackup(restoreDir, backupDirs[1:]...)
+	assert.Equal(t, ErrInvalidBackupChain, err)
+
+	// 缺少中间的增量备份
+	assert.Nil(t, os.RemoveAll(restoreDir))
+	err = RestoreBackup(restoreDir, backupDirs[0], backupDirs[2])
+	assert.Equal(t, ErrInvalidBackupChain, err)
+
+	assert.Nil(t, os.RemoveAll(restoreDir))
+	err = RestoreBackup(restoreDir, backupDirs...)
+	assert.Nil(t, err)
+}
+
+func TestDB_BackupSinceBPlusTree(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup-since-bptree")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	fullDir, incDir, restoreDir := newTestBackupDir(t), newTestBackupDir(t), newTestBackupDir(t)
+	defer os.RemoveAll(fullDir)
+	defer os.RemoveAll(incDir)
+	defer os.RemoveAll(restoreDir)
+
+	putTestKeys(t, db, 0, 100)
+	full, err := db.BackupSince(nil, fullDir)
+	assert.Nil(t, err)
+	putTestKeys(t, db, 100, 200)
+	_, err = db.BackupSince(full, incDir)
+	assert.Nil(t, err)
+	putTestKeys(t, db, 200, 300)
+
+	assert.Nil(t, RestoreBackup(restoreDir, fullDir, incDir))
+	restoreOpts := opts
+	restoreOpts.DirPath = restoreDir
+	restoreDB, err := Open(restoreOpts)
+	assert.Nil(t, err)
+	defer restoreDB.Close()
+	for i := 0; i < 200; i++ {
+		val, err := restoreDB.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+	_, err = restoreDB.Get(utils.GetTestKey(200))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestDB_BackupSeqNoAfterRestore(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup-seq-no")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	fullDir, incDir, onlineDir, restoreDir := newTestBackupDir(t), newTestBackupDir(t), newTestBackupDir(t), newTestBackupDir(t)
+	defer os.RemoveAll(fullDir)
+	defer os.RemoveAll(incDir)
+	defer os.RemoveAll(onlineDir)
+	defer os.RemoveAll(restoreDir)
+
+	// 有watcher时普通写入也分配序列号
+	ch, cancel := db.Watch(nil)
+	putTestKeys(t, db, 0, 10)
+	full, err := db.BackupSince(nil, fullDir)
+	assert.Nil(t, err)
+	putTestKeys(t, db, 10, 20)
+	_, err = db.BackupSince(full, incDir)
+	assert.Nil(t, err)
+	err = db.Backup(onlineDir)
+	assert.Nil(t, err)
+	var lastSeqNo uint64
+	for i := 0; i < 20; i++ {
+		lastSeqNo = receiveEvent(t, ch).SeqNo
+	}
+	cancel()
+
+	err = RestoreBackup(restoreDir, fullDir, incDir)
+	assert.Nil(t, err)
+	for _, backupDir := range []string{restoreDir, onlineDir} {
+		backupOpts := opts
+		backupOpts.DirPath = backupDir
+		backupDB, err := Open(backupOpts)
+		assert.Nil(t, err)
+		ch, cancel := backupDB.Watch(nil)
+		assert.Nil(t, backupDB.Put(utils.GetTestKey(20), utils.GetTestKey(20)))
+		event := receiveEvent(t, ch)
+		assert.Greater(t, event.SeqNo, lastSeqNo)
+		cancel()
+		assert.Nil(t, backupDB.Close())
+	}
+}
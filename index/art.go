@@ -0,0 +1,168 @@
+package index
+
+import (
+	"bytes"
+	"kv-go/data"
+	"sort"
+	"sync"
+
+	goart "github.com/plar/go-adaptive-radix-tree"
+)
+
+// 自适应基数树索引，key有相同前缀时只储存一次前缀，比btree更节省内存
+type AdaptiveRadixTree struct {
+	tree goart.Tree
+	lock *sync.RWMutex
+}
+
+func NewART() *AdaptiveRadixTree {
+	return &AdaptiveRadixTree{
+		tree: goart.New(),
+		lock: new(sync.RWMutex),
+	}
+}
+
+func (art *AdaptiveRadixTree) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
+	art.lock.Lock()
+	oldValue, _ := art.tree.Insert(key, pos)
+	art.lock.Unlock()
+	if oldValue == nil {
+		return nil
+	}
+	return oldValue.(*data.LogRecordPos)
+}
+
+func (art *AdaptiveRadixTree) Get(key []byte) *data.LogRecordPos {
+	art.lock.RLock()
+	defer art.lock.RUnlock()
+	value, found := art.tree.Search(key)
+	if !found {
+		return nil
+	}
+	return value.(*data.LogRecordPos)
+}
+
+func (art *AdaptiveRadixTree) Delete(key []byte) (*data.LogRecordPos, bool) {
+	art.lock.Lock()
+	oldValue, deleted := art.tree.Delete(key)
+	art.lock.Unlock()
+	if oldValue == nil {
+		return nil, false
+	}
+	return oldValue.(*data.LogRecordPos), deleted
+}
+
+func (art *AdaptiveRadixTree) Size() int {
+	art.lock.RLock()
+	size := art.tree.Size()
+	art.lock.RUnlock()
+	return size
+}
+
+func (art *AdaptiveRadixTree) Close() error {
+	return nil
+}
+
+// art不支持写时复制，需要把数据拷贝到一棵新的树中
+func (art *AdaptiveRadixTree) Clone() Indexer {
+	art.lock.RLock()
+	defer art.lock.RUnlock()
+	tree := goart.New()
+	art.tree.ForEach(func(node goart.Node) bool {
+		tree.Insert(node.Key(), node.Value())
+		return true
+	})
+	return &AdaptiveRadixTree{
+		tree: tree,
+		lock: new(sync.RWMutex),
+	}
+}
+
+func (art *AdaptiveRadixTree) Iterator(reverse bool) Iterator {
+	return art.PrefixIterator(nil, reverse)
+}
+
+// 只拷贝前缀下的数据，前缀相同的key在art中是同一棵子树
+func (art *AdaptiveRadixTree) PrefixIterator(prefix []byte, reverse bool) Iterator {
+	art.lock.RLock()
+	defer art.lock.RUnlock()
+	return newARTIterator(art.tree, prefix, reverse)
+}
+
+// 和btreeIterator一样，创建时把索引数据拷贝出来，有前缀时只拷贝前缀下的数据
+type artIterator struct {
+	currIndex int     // 当前遍历位置
+	reverse   bool    // 是否是反向的遍历
+	values    []*Item //遍历结果
+}
+
+func newARTIterator(tree goart.Tree, prefix []byte, reverse bool) *artIterator {
+	var values []*Item
+	saveValues := func(node goart.Node) bool {
+		// ForEachPrefix也会遍历内部节点
+		if node.Kind() != goart.Leaf || !bytes.HasPrefix(node.Key(), prefix) {
+			return true
+		}
+		values = append(values, &Item{
+			key: node.Key(),
+			pos: node.Value().(*data.LogRecordPos),
+		})
+		return true
+	}
+	if len(prefix) == 0 {
+		values = make([]*Item, 0, tree.Size())
+		tree.ForEach(saveValues)
+	} else {
+		tree.ForEachPrefix(prefix, saveValues)
+	}
+
+	// art按照key的字节序遍历叶子节点，反向遍历时倒过来
+	if reverse {
+		for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	return &artIterator{
+		currIndex: 0,
+		reverse:   reverse,
+		values:    values,
+	}
+}
+
+func (iter *artIterator) Rewind() {
+	iter.currIndex = 0
+}
+
+//寻找小于或大于目标的第一个key的位置
+func (iter *artIterator) Seek(key []byte) {
+	if iter.reverse {
+		iter.currIndex = sort.Search(len(iter.values), func(i int) bool {
+			return bytes.Compare(iter.values[i].key, key) <= 0
+		})
+	} else {
+		iter.currIndex = sort.Search(len(iter.values), func(i int) bool {
+			return bytes.Compare(iter.values[i].key, key) >= 0
+		})
+	}
+}
+
+func (iter *artIterator) Next() {
+	iter.currIndex += 1
+}
+
+func (iter *artIterator) Valid() bool {
+	return iter.currIndex < len(iter.values)
+}
+
+func (iter *artIterator) Key() []byte {
+	return iter.values[iter.currIndex].key
+}
+
+func (iter *artIterator) Value() *data.LogRecordPos {
+	return iter.values[iter.currIndex].pos
+}
+
+func (iter *artIterator) Close() {
+	iter.values = nil
+}
@@ -0,0 +1,247 @@
+package index
+
+import (
+	"io"
+	"kv-go/data"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const BPlusTreeIndexFileName = "bptree-index"
+
+// b+树索引文件的初始映射大小，只占用虚拟内存
+const initialMmapSize = 1 << 30
+
+// 索引文件被其他进程锁住时，等待这么久之后返回错误
+const openTimeout = time.Second
+
+var indexBucketName = []byte("kv-go-index")
+
+// 磁盘上的b+树索引，索引数据储存在数据目录的bptree-index文件中
+// 索引不需要全部放进内存，重启时也不需要遍历数据文件来构建索引
+type BPlusTree struct {
+	tree *bbolt.DB
+}
+
+// 打开或者创建索引文件，文件损坏或者被其他进程锁住时返回错误
+func NewBPlusTree(dirPath string, syncWrites bool) (*BPlusTree, error) {
+	opts := *bbolt.DefaultOptions
+	opts.NoSync = !syncWrites
+	opts.Timeout = openTimeout
+	// bbolt扩大mmap时需要等待所有只读事务结束，初始映射得大一些，避免快照和迭代器阻塞写入
+	opts.InitialMmapSize = initialMmapSize
+	bptree, err := bbolt.Open(filepath.Join(dirPath, BPlusTreeIndexFileName), 0644, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建bucket
+	if err := bptree.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(indexBucketName)
+		return err
+	}); err != nil {
+		_ = bptree.Close()
+		return nil, err
+	}
+
+	return &BPlusTree{tree: bptree}, nil
+}
+
+func (bpt *BPlusTree) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
+	var oldValue []byte
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		// 旧的value只在事务中有效，需要先解码
+		if value := bucket.Get(key); value != nil {
+			oldValue = append([]byte{}, value...)
+		}
+		return bucket.Put(key, data.EncodeLogRecordPos(pos))
+	}); err != nil {
+		panic("failed to put value in bptree")
+	}
+	if len(oldValue) == 0 {
+		return nil
+	}
+	return data.DecodeLogRecordPos(oldValue)
+}
+
+func (bpt *BPlusTree) Get(key []byte) *data.LogRecordPos {
+	var pos *data.LogRecordPos
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket(indexBucketName).Get(key)
+		if len(value) != 0 {
+			pos = data.DecodeLogRecordPos(value)
+		}
+		return nil
+	}); err != nil {
+		panic("failed to get value in bptree")
+	}
+	return pos
+}
+
+func (bpt *BPlusTree) Delete(key []byte) (*data.LogRecordPos, bool) {
+	var oldValue []byte
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		if value := bucket.Get(key); value != nil {
+			oldValue = append([]byte{}, value...)
+			return bucket.Delete(key)
+		}
+		return nil
+	}); err != nil {
+		panic("failed to delete value in bptree")
+	}
+	if len(oldValue) == 0 {
+		return nil, false
+	}
+	return data.DecodeLogRecordPos(oldValue), true
+}
+
+func (bpt *BPlusTree) Size() int {
+	var size int
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		size = tx.Bucket(indexBucketName).Stats().KeyN
+		return nil
+	}); err != nil {
+		panic("failed to get size in bptree")
+	}
+	return size
+}
+
+func (bpt *BPlusTree) Close() error {
+	return bpt.tree.Close()
+}
+
+func (bpt *BPlusTree) Iterator(reverse bool) Iterator {
+	tx, err := bpt.tree.Begin(false)
+	if err != nil {
+		panic("failed to begin a transaction")
+	}
+	return newBptreeIterator(tx, reverse, true)
+}
+
+// 用一个只读事务作为副本，事务开始之后的修改对它不可见
+func (bpt *BPlusTree) Clone() Indexer {
+	tx, err := bpt.tree.Begin(false)
+	if err != nil {
+		panic("failed to begin a transaction")
+	}
+	return &bptreeSnapshot{tx: tx}
+}
+
+// b+树的只读副本，不能写入，用完之后需要Close结束事务
+// 和bbolt的事务一样，不要在多个goroutine中同时使用
+type bptreeSnapshot struct {
+	tx *bbolt.Tx
+}
+
+func (snap *bptreeSnapshot) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
+	panic("bptree snapshot is read only")
+}
+
+func (snap *bptreeSnapshot) Get(key []byte) *data.LogRecordPos {
+	value := snap.tx.Bucket(indexBucketName).Get(key)
+	if len(value) == 0 {
+		return nil
+	}
+	return data.DecodeLogRecordPos(value)
+}
+
+func (snap *bptreeSnapshot) Delete(key []byte) (*data.LogRecordPos, bool) {
+	panic("bptree snapshot is read only")
+}
+
+func (snap *bptreeSnapshot) Size() int {
+	return snap.tx.Bucket(indexBucketName).Stats().KeyN
+}
+
+func (snap *bptreeSnapshot) Close() error {
+	return snap.tx.Rollback()
+}
+
+func (snap *bptreeSnapshot) Clone() Indexer {
+	return snap
+}
+
+// 把副本写入w，得到一个完整的b+树索引文件，用于在线备份
+func (snap *bptreeSnapshot) WriteTo(w io.Writer) (int64, error) {
+	return snap.tx.WriteTo(w)
+}
+
+// 迭代器和副本共用一个事务，迭代器关闭时不结束事务
+func (snap *bptreeSnapshot) Iterator(reverse bool) Iterator {
+	return newBptreeIterator(snap.tx, reverse, false)
+}
+
+// b+树迭代器，不拷贝数据，持有一个只读事务，用游标遍历
+// 索引文件超过initialMmapSize之后，bbolt扩容时会等待只读事务结束，迭代器打开期间不要在同一个goroutine中写入
+type bptreeIterator struct {
+	tx        *bbolt.Tx
+	ownTx     bool // 是否由迭代器结束事务
+	cursor    *bbolt.Cursor
+	reverse   bool
+	currKey   []byte
+	currValue []byte
+}
+
+func newBptreeIterator(tx *bbolt.Tx, reverse bool, ownTx bool) *bptreeIterator {
+	iter := &bptreeIterator{
+		tx:      tx,
+		ownTx:   ownTx,
+		cursor:  tx.Bucket(indexBucketName).Cursor(),
+		reverse: reverse,
+	}
+	iter.Rewind()
+	return iter
+}
+
+func (iter *bptreeIterator) Rewind() {
+	if iter.reverse {
+		iter.currKey, iter.currValue = iter.cursor.Last()
+	} else {
+		iter.currKey, iter.currValue = iter.cursor.First()
+	}
+}
+
+//寻找小于或大于目标的第一个key的位置
+func (iter *bptreeIterator) Seek(key []byte) {
+	iter.currKey, iter.currValue = iter.cursor.Seek(key)
+	if !iter.reverse {
+		return
+	}
+	// 反向遍历时，seek到的是第一个大于等于key的位置，不相等就往前移一个
+	if iter.currKey == nil {
+		iter.currKey, iter.currValue = iter.cursor.Last()
+	} else if string(iter.currKey) != string(key) {
+		iter.currKey, iter.currValue = iter.cursor.Prev()
+	}
+}
+
+func (iter *bptreeIterator) Next() {
+	if iter.reverse {
+		iter.currKey, iter.currValue = iter.cursor.Prev()
+	} else {
+		iter.currKey, iter.currValue = iter.cursor.Next()
+	}
+}
+
+func (iter *bptreeIterator) Valid() bool {
+	return len(iter.currKey) != 0
+}
+
+// 游标返回的key只在事务中有效，拷贝一份返回
+func (iter *bptreeIterator) Key() []byte {
+	return append([]byte{}, iter.currKey...)
+}
+
+func (iter *bptreeIterator) Value() *data.LogRecordPos {
+	return data.DecodeLogRecordPos(iter.currValue)
+}
+
+func (iter *bptreeIterator) Close() {
+	if iter.ownTx {
+		_ = iter.tx.Rollback()
+	}
+}
@@ -35,7 +35,9 @@ func (bt *BTree) Put (key []byte, pos *data.LogRecordPos) *data.LogRecordPos{
 
 func (bt *BTree) Get(key []byte) *data.LogRecordPos {
 	it := &Item{key: key}
+	bt.lock.RLock()
 	btreeItem := bt.tree.Get(it)
+	bt.lock.RUnlock()
 	if btreeItem == nil {
 		return nil
 	}
@@ -57,7 +59,19 @@ func (bt *BTree) Close() error {
 	return nil
 }
 
+// btree的clone是写时复制的，不需要拷贝全部数据
+func (bt *BTree) Clone() Indexer {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	return &BTree{
+		tree: bt.tree.Clone(),
+		lock: new(sync.RWMutex),
+	}
+}
+
 func (bt *BTree) Size() int {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
 	return bt.tree.Len()
 }
 
@@ -13,7 +13,21 @@ type Indexer interface{
 	Iterator(reverse bool) Iterator
 	Size() int
 	Close() error
+	Clone() Indexer // 返回当前索引的副本，之后对原索引的修改不影响副本，用于快照读取
 }
+// 支持只遍历一个前缀的索引，不需要遍历或者拷贝前缀之外的数据
+type PrefixIndexer interface{
+	PrefixIterator(prefix []byte, reverse bool) Iterator
+}
+
+// 有前缀并且索引支持时只遍历前缀下的数据，返回的迭代器中也可能有前缀之外的key，调用者还需要过滤
+func NewPrefixIterator(indexer Indexer, prefix []byte, reverse bool) Iterator{
+	if prefixIndexer, ok := indexer.(PrefixIndexer); ok && len(prefix) > 0 {
+		return prefixIndexer.PrefixIterator(prefix, reverse)
+	}
+	return indexer.Iterator(reverse)
+}
+
 // 储存key和数据位置
 type Item struct{
 	key []byte
@@ -25,16 +39,31 @@ type IndexType = int8
 const (
 	Btree IndexType = iota + 1
 
+	// 自适应基数树
+	ART
+
+	// 磁盘b+树
+	BPTree
 )
 func (ai *Item) Less(bi btree.Item) bool{
 	return bytes.Compare(ai.key, bi.(*Item).key) == -1
 }
 
 
-func NewIndexer(t IndexType) Indexer{
+// dirPath和syncWrites只有磁盘索引会用到
+// 只有b+树索引会打开文件，可能返回错误
+func NewIndexer(t IndexType, dirPath string, syncWrites bool) (Indexer, error){
 	switch t{
 	case Btree:
-		return NewBtree()
+		return NewBtree(), nil
+	case ART:
+		return NewART(), nil
+	case BPTree:
+		bpt, err := NewBPlusTree(dirPath, syncWrites)
+		if err != nil {
+			return nil, err
+		}
+		return bpt, nil
 	default:
 		panic("unsupported index type")
 	}
@@ -8,11 +8,13 @@ import (
 type Iterator struct{
 	indexIter index.Iterator
 	db *DB
+	snapshot *Snapshot // 从快照创建的迭代器从快照的数据文件中读取
+	txn *Txn // 从事务创建的迭代器优先读取事务中的数据
 	config IteratorConfig
 }
 
 func (db *DB) NewIterator(config IteratorConfig) *Iterator{
-	indexIter := db.index.Iterator(config.Reverse)
+	indexIter := index.NewPrefixIterator(db.index, config.Prefix, config.Reverse)
 	return &Iterator{
 		db:db,
 		indexIter: indexIter,
@@ -45,8 +47,17 @@ func (iter *Iterator) Key() []byte{
 
 func (iter *Iterator) Value() ([]byte,error){
 	logRecordPos := iter.indexIter.Value()
+	if logRecordPos.IsExpired() {
+		return nil, ErrKeyNotFound
+	}
+	if iter.txn != nil {
+		return iter.txn.getValue(iter.Key(), logRecordPos)
+	}
 	iter.db.mu.RLock()
 	defer iter.db.mu.RUnlock()
+	if iter.snapshot != nil {
+		return iter.snapshot.getValueByPosition(logRecordPos)
+	}
 	return iter.db.getValueByPosition(logRecordPos)
 }
 
@@ -54,15 +65,15 @@ func(iter *Iterator) Close() {
 	iter.indexIter.Close()
 }
 
-//过滤prefix, next指针指向符合的数据
+//过滤prefix和已经过期的数据, next指针指向符合的数据
 func (iter *Iterator) skipToNext(){
 	prefixLen := len(iter.config.Prefix)
-	if prefixLen == 0 {
-		return
-	}
 	for ; iter.indexIter.Valid();iter.indexIter.Next(){
+		if iter.indexIter.Value().IsExpired() {
+			continue
+		}
 		key := iter.indexIter.Key()
-		if prefixLen <= len(key) && bytes.Compare(iter.config.Prefix,key[:prefixLen]) == 0 {
+		if prefixLen == 0 || prefixLen <= len(key) && bytes.Compare(iter.config.Prefix,key[:prefixLen]) == 0 {
 			break
 		}
 	}
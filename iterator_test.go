@@ -1,6 +1,7 @@
 package kv_go
 
 import (
+	"kv-go/index"
 	"kv-go/utils"
 	"github.com/stretchr/testify/assert"
 	"os"
@@ -95,4 +96,85 @@ func TestDB_Iterator_Multi_Values(t *testing.T) {
 		assert.NotNil(t, iter3.Key())
 	}
 	iter3.Close()
-}
\ No newline at end of file
+}
+func TestDB_Iterator_ART(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-iterator-art")
+	opts.DirPath = dir
+	opts.IndexType = ART
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	keys := []string{"tenant/1/user/b", "tenant/1/user/a", "tenant/2/user/a", "tenant/1", "aaa"}
+	for _, key := range keys {
+		err = db.Put([]byte(key), []byte(key))
+		assert.Nil(t, err)
+	}
+
+	// 正向迭代，按照字节序
+	var got []string
+	iter1 := db.NewIterator(DefaultIteratorConfig)
+	for iter1.Rewind(); iter1.Valid(); iter1.Next() {
+		got = append(got, string(iter1.Key()))
+	}
+	iter1.Close()
+	assert.Equal(t, []string{"aaa", "tenant/1", "tenant/1/user/a", "tenant/1/user/b", "tenant/2/user/a"}, got)
+
+	// 反向迭代 + seek
+	iterOpts1 := DefaultIteratorConfig
+	iterOpts1.Reverse = true
+	iter2 := db.NewIterator(iterOpts1)
+	iter2.Seek([]byte("tenant/1/user/az"))
+	assert.True(t, iter2.Valid())
+	assert.Equal(t, []byte("tenant/1/user/a"), iter2.Key())
+	iter2.Close()
+
+	// 指定了 prefix
+	got = nil
+	iterOpts2 := DefaultIteratorConfig
+	iterOpts2.Prefix = []byte("tenant/1/")
+	iter3 := db.NewIterator(iterOpts2)
+	for iter3.Rewind(); iter3.Valid(); iter3.Next() {
+		val, err := iter3.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, iter3.Key(), val)
+		got = append(got, string(iter3.Key()))
+	}
+	iter3.Close()
+	assert.Equal(t, []string{"tenant/1/user/a", "tenant/1/user/b"}, got)
+
+	// 反向 + prefix
+	got = nil
+	iterOpts3 := DefaultIteratorConfig
+	iterOpts3.Prefix = []byte("tenant/1")
+	iterOpts3.Reverse = true
+	iter4 := db.NewIterator(iterOpts3)
+	for iter4.Rewind(); iter4.Valid(); iter4.Next() {
+		got = append(got, string(iter4.Key()))
+	}
+	iter4.Close()
+	assert.Equal(t, []string{"tenant/1/user/b", "tenant/1/user/a", "tenant/1"}, got)
+
+	// 索引迭代器只包含前缀下的key
+	indexIter := index.NewPrefixIterator(db.index, []byte("tenant/1/"), false)
+	got = nil
+	for indexIter.Rewind(); indexIter.Valid(); indexIter.Next() {
+		got = append(got, string(indexIter.Key()))
+	}
+	indexIter.Close()
+	assert.Equal(t, []string{"tenant/1/user/a", "tenant/1/user/b"}, got)
+
+	// 删除之后重启
+	err = db.Delete([]byte("aaa"))
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(4), db2.Stat().KeyNum)
+	_, err = db2.Get([]byte("aaa"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_ = db2.Close()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	kv_go "kv-go"
+	"os"
+)
+
+// 数据目录的离线维护工具
+// kvctl <command> [arguments]
+
+// 参数不正确时返回，打印这个命令的用法
+var errUsage = errors.New("wrong arguments")
+
+type command struct {
+	name string
+	args string
+	help string
+	run  func(args []string) error
+}
+
+var commands = []*command{
+	{name: "verify", args: "<dir>", help: "check crc, transactions and hint index of a data directory", run: runVerify},
+	{name: "repair", args: "<dir>", help: "salvage readable records, the original directory is kept as <dir>-corrupted", run: runRepair},
+	{name: "dump", args: "<dir> [file]", help: "export all keys as json lines, to stdout when file is omitted", run: runDump},
+	{name: "load", args: "[-batch n] <dir> [file]", help: "import json lines written by dump, from stdin when file is omitted", run: runLoad},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kvctl <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-30s %s\n", cmd.name+" "+cmd.args, cmd.help)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		err := cmd.run(os.Args[2:])
+		if err == errUsage {
+			fmt.Fprintf(os.Stderr, "usage: kvctl %s %s\n", cmd.name, cmd.args)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kvctl %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+	os.Exit(2)
+}
+
+// 检查出问题时以非0状态退出，可以在脚本中检查备份
+func runVerify(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	report, err := kv_go.Verify(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("data files: %d, records: %d, hint records: %d\n", report.DataFiles, report.Records, report.HintRecords)
+	for _, warning := range report.Warnings {
+		fmt.Println("warning:", warning)
+	}
+	for _, problem := range report.Problems {
+		fmt.Println(problem)
+	}
+	if !report.OK() {
+		return fmt.Errorf("found %d problems", len(report.Problems))
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func runRepair(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	report, err := kv_go.Repair(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("records: %d, corrupt regions: %d, skipped bytes: %d\n", report.Records, report.CorruptRegions, report.SkippedBytes)
+	fmt.Printf("recovered keys: %d, lost keys: %d, keys restored to an older version: %d\n", report.RecoveredKeys, report.LostKeys, report.StaleKeys)
+	fmt.Printf("the original directory is kept in %s\n", report.CorruptedDir)
+	return nil
+}
+
+// 只读模式打开，正在运行的db也可以导出
+func runDump(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	options := kv_go.DefaultConfig
+	options.DirPath = args[0]
+	options.ReadOnly = true
+	db, err := kv_go.Open(options)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	if len(args) == 2 {
+		f, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := db.Export(w)
+	if err != nil {
+		return err
+	}
+	// 数据可能写到了标准输出，统计信息写到标准错误
+	fmt.Fprintf(os.Stderr, "dumped %d keys\n", n)
+	return nil
+}
+
+func runLoad(args []string) error {
+	flagSet := flag.NewFlagSet("load", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	batchSize := flagSet.Uint("batch", kv_go.DefaultImportConfig.BatchSize, "number of keys in one write batch")
+	if err := flagSet.Parse(args); err != nil {
+		return errUsage
+	}
+	args = flagSet.Args()
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+
+	var r io.Reader = os.Stdin
+	if len(args) == 2 {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	options := kv_go.DefaultConfig
+	options.DirPath = args[0]
+	db, err := kv_go.Open(options)
+	if err != nil {
+		return err
+	}
+	config := kv_go.DefaultImportConfig
+	config.BatchSize = *batchSize
+	n, err := db.ImportWithConfig(r, config)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "loaded %d keys\n", n)
+	return nil
+}
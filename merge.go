@@ -21,6 +21,9 @@ const (
 )
 
 func (db *DB) Merge() error {
+	if db.config.ReadOnly {
+		return ErrReadOnly
+	}
 	if db.activeFile == nil {
 		return nil
 	}
@@ -28,12 +31,15 @@ func (db *DB) Merge() error {
 	db.mu.Lock()
 
 	if db.isMerging {
+		db.mu.Unlock()
 		return ErrMergeInProcess
 	}
 
 	db.isMerging = true
 	defer func() {
+		db.mu.Lock()
 		db.isMerging = false
+		db.mu.Unlock()
 	}()
 
 	//
@@ -70,7 +76,7 @@ func (db *DB) Merge() error {
 
 	mergePath := db.getMergePath()
 	// 如果存在merge文件，删除
-	if _, err := os.Stat(mergePath); err != nil {
+	if _, err := os.Stat(mergePath); err == nil {
 		if err := os.RemoveAll(mergePath); err != nil {
 			return err
 		}
@@ -84,10 +90,18 @@ func (db *DB) Merge() error {
 	mergeConfig := db.config
 	mergeConfig.DirPath = mergePath
 	mergeConfig.SyncWrites = false
+	// merge结束时会统一持久化，不需要后台持久化
+	mergeConfig.BytesPerSync = 0
+	mergeConfig.SyncInterval = 0
+	// mergeDB只用来写数据文件，使用内存索引，避免在merge目录中创建b+树索引文件
+	mergeConfig.IndexType = Btree
 	mergeDB, err := Open(mergeConfig)
 	if err != nil {
 		return nil
 	}
+	defer func() {
+		_ = mergeDB.Close()
+	}()
 
 	// 打开hint文件，储存索引
 	hintFile, err := data.OpenHintFile(mergePath)
@@ -107,8 +121,8 @@ func (db *DB) Merge() error {
 			}
 			key, _ := parseSeqLogRecordKey(logRecord.Key)
 			logRecordPos := db.index.Get(key)
-			// 和内存进行比较
-			if logRecordPos != nil && logRecordPos.Fid == dataFile.FileId && logRecordPos.Offset == offset {
+			// 和内存进行比较，已经过期的数据不再写入merge文件和hint文件
+			if logRecordPos != nil && logRecordPos.Fid == dataFile.FileId && logRecordPos.Offset == offset && !logRecordPos.IsExpired() {
 				// 把batch的key前缀都变成nonTxnSeqNo
 				logRecord.Key = createLogRecordKeyWithSeq(key, nonTxnSeqNo)
 				pos, err := mergeDB.appendLogRecord(logRecord)
@@ -155,10 +169,11 @@ func (db *DB) Merge() error {
 		return err
 	}
 
-	err = db.loadMergeFiles()
-	if err != nil {
-		return err 
-	}
+	// 运行中不替换数据目录中的文件，快照、迭代器和备份使用的旧文件一直有效
+	// 下次Open时加载merge文件，b+树索引中的位置也在那时根据hint文件更新
+	db.mu.Lock()
+	db.mergePending = true
+	db.mu.Unlock()
 	return nil
 }
 
@@ -176,8 +191,12 @@ func (db *DB) loadMergeFiles() error{
 		return nil
 	}
 	//删除merge文件
+	//开始替换数据文件之后出错时保留merge目录，下次Open时重新加载，重新加载会再次删除和拷贝，结果是一样的
+	removeMergeDir := true
 	defer func(){
-		_ = os.RemoveAll(mergePath)
+		if removeMergeDir {
+			_ = os.RemoveAll(mergePath)
+		}
 	}()
 
 	dirEntries,err := os.ReadDir(mergePath)
@@ -193,6 +212,10 @@ func (db *DB) loadMergeFiles() error{
 		if entry.Name() == data.MergeFinishedFileName{
 			mergeFinished = true
 		}
+		// 文件锁不需要拷贝
+		if entry.Name() == fileLockName {
+			continue
+		}
 		mergeFileNames = append(mergeFileNames, entry.Name())
 	}
 
@@ -207,6 +230,7 @@ func (db *DB) loadMergeFiles() error{
 	}
 
 	// 删除旧的数据文件
+	removeMergeDir = false
 	var fileId uint32 = 0
 	
 	//小于nonMergeFileId的文件都是merge过的文件
@@ -247,6 +271,38 @@ func (db *DB) loadMergeFiles() error{
 		dstFile.Close()
 	}
 
+	// b+树索引更新完成之后才能删除merge目录，否则索引会指向已经删除的数据文件
+	if db.config.IndexType == BPlusTree {
+		if err := db.updateIndexFromHintFile(nonMergeFileId); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(mergePath)
+}
+
+// b+树索引储存的还是merge之前的位置，根据hint文件更新
+// 只更新位置在merge过的文件中的key，merge之后写入或删除的key以b+树中的为准
+func (db *DB) updateIndexFromHintFile(nonMergeFileId uint32) error {
+	hintFile, err := data.OpenHintFile(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+	defer hintFile.Close()
+
+	var offset int64 = 0
+	for {
+		logRecord,size,err := hintFile.Read(offset)
+		if err != nil {
+			if err == io.EOF{
+				break
+			}
+			return err
+		}
+		if oldPos := db.index.Get(logRecord.Key); oldPos != nil && oldPos.Fid < nonMergeFileId {
+			db.index.Put(logRecord.Key, data.DecodeLogRecordPos(logRecord.Value))
+		}
+		offset = offset + size
+	}
 	return nil
 }
 
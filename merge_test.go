@@ -1,11 +1,13 @@
 package kv_go
 
 import (
+	"kv-go/data"
 	"kv-go/utils"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -164,3 +166,121 @@ func Test_close(t *testing.T){
 	assert.Nil(t,db.index)
 }
 
+
+// 过期的数据在merge时被丢弃
+func TestDB_MergeExpired(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-ttl")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.PutWithTTL(utils.GetTestKey(i), utils.RandomValue(128), 50*time.Millisecond)
+		assert.Nil(t, err)
+	}
+	for i := 1000; i < 2000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 重启后只剩下没有过期的数据，hint文件中也没有过期的key
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, len(db2.ListKeys()))
+	assert.Equal(t, uint(1000), db2.Stat().KeyNum)
+	_, err = db2.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_ = db2.Close()
+}
+
+// b+树索引在重启时根据hint文件更新merge之后的位置
+func TestDB_MergeBPlusTree(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-bptree")
+	opts.DirPath = dir
+	opts.DataFileSize = 1024 * 1024
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 5000; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	// merge之后写入的数据
+	for i := 9000; i < 10000; i++ {
+		err := db.Put(utils.GetTestKey(i), []byte("new value in merge"))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(utils.GetTestKey(5000))
+	assert.Nil(t, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 4999, len(db2.ListKeys()))
+	_, err = db2.Get(utils.GetTestKey(5000))
+	assert.Equal(t, ErrKeyNotFound, err)
+	for i := 5001; i < 9000; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.NotNil(t, val)
+	}
+	for i := 9000; i < 10000; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("new value in merge"), val)
+	}
+	_ = db2.Close()
+}
+
+func TestDB_MergeBPlusTreeHintError(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-bptree-hint")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	mergePath := db.getMergePath()
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(mergePath)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+
+	// 破坏merge目录中的hint文件，更新b+树索引会失败
+	hintPath := filepath.Join(mergePath, data.HintFileName)
+	buf, err := os.ReadFile(hintPath)
+	assert.Nil(t, err)
+	buf[len(buf)-1] ^= 0xff
+	assert.Nil(t, os.WriteFile(hintPath, buf, 0644))
+
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+	// 索引没有更新完成，merge目录要保留
+	_, err = os.Stat(mergePath)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,95 @@
+package kv_go
+
+import (
+	"io"
+	"kv-go/data"
+	"log"
+)
+
+// 写入数据时进程崩溃，活跃文件的最后一条数据可能只写入了一部分
+// 读取到这里时会出现crc校验失败，或者剩余的字节不够一条完整的数据
+// 启动时把活跃文件截断到最后一条完整数据的结束位置，后面的写入不会和损坏的数据混在一起
+// 损坏的位置之后还有有效的数据时，不是写入时崩溃造成的，截断会丢失数据，直接返回错误
+// 旧的数据文件在切换活跃文件时已经持久化了，出错时还是直接返回错误
+
+// offset是最后一条完整数据的结束位置，loadErr是加载活跃文件时的错误
+func (db *DB) recoverActiveFile(offset int64, loadErr error) (int64, error) {
+	if loadErr != nil && loadErr != data.ErrInvalidCRC && loadErr != io.ErrUnexpectedEOF {
+		return offset, loadErr
+	}
+
+	// 只读模式下写入的进程可能正在追加数据，和Refresh一样读到最后一条完整的数据，不截断
+	if db.config.ReadOnly {
+		return offset, nil
+	}
+
+	size, err := db.activeFile.IOManager.Size()
+	if err != nil {
+		return offset, err
+	}
+	if offset >= size {
+		return offset, loadErr
+	}
+
+	if db.config.StrictRecovery {
+		if loadErr != nil {
+			return offset, loadErr
+		}
+		return offset, ErrDataDirectoryCorrupted
+	}
+
+	// 损坏位置之后的数据一次读到内存中，逐个字节查找有效数据，不用每个位置都读一次文件
+	tail := make([]byte, size-offset)
+	if _, err := db.activeFile.IOManager.Read(tail, offset); err != nil && err != io.EOF {
+		return offset, err
+	}
+	for i := 1; i < len(tail); i++ {
+		if data.IsValidLogRecord(tail[i:]) {
+			log.Printf("kv-go: data file %09d is corrupted at offset %d, valid records found at offset %d, use kvctl repair",
+				db.activeFile.FileId, offset, offset+int64(i))
+			return offset, ErrDataDirectoryCorrupted
+		}
+	}
+
+	if err := db.activeFile.Truncate(db.config.DirPath, offset); err != nil {
+		return offset, err
+	}
+	log.Printf("kv-go: truncated corrupt tail of data file %09d, discarded %d bytes at offset %d",
+		db.activeFile.FileId, size-offset, offset)
+	return offset, nil
+}
+
+// b+树索引不遍历数据文件，只读取活跃文件找到最后一条完整数据的结束位置，作为活跃文件的writeoff
+func (db *DB) recoverBPlusTreeActiveFile() error {
+	if db.activeFile == nil {
+		return nil
+	}
+
+	var offset int64 = 0
+	var loadErr error
+	for {
+		_, size, err := db.activeFile.Read(offset)
+		if err != nil {
+			if err != io.EOF {
+				loadErr = err
+			}
+			break
+		}
+		if size == 0 {
+			break
+		}
+		offset += size
+	}
+
+	offset, err := db.recoverActiveFile(offset, loadErr)
+	if err != nil {
+		return err
+	}
+	db.activeFile.WriteOffset = offset
+	return nil
+}
+
+func validRecordAt(dataFile *data.DataFile, offset int64) bool {
+	logRecord, size, err := dataFile.Read(offset)
+	return err == nil && logRecord != nil && size > 0
+}
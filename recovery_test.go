@@ -0,0 +1,199 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 在活跃文件末尾追加数据，模拟写入时崩溃
+func appendToActiveFile(t *testing.T, dirPath string, fid uint32, buf []byte) {
+	f, err := os.OpenFile(data.GetDatafilePath(dirPath, fid), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	_, err = f.Write(buf)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+}
+
+func TestDB_RecoverTornWrite(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-recover")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	fid, goodSize := db.activeFile.FileId, db.activeFile.WriteOffset
+	assert.Nil(t, db.Close())
+
+	// 最后一条数据只写入了一半
+	record, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   createLogRecordKeyWithSeq([]byte("torn-key"), nonTxnSeqNo),
+		Value: utils.RandomValue(128),
+		Type:  data.LogRecordNormal,
+	})
+	appendToActiveFile(t, dir, fid, record[:len(record)/2])
+
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, goodSize, db.activeFile.WriteOffset)
+	stat, err := os.Stat(data.GetDatafilePath(dir, fid))
+	assert.Nil(t, err)
+	assert.Equal(t, goodSize, stat.Size())
+	_, err = db.Get([]byte("torn-key"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 截断之后可以继续写入
+	err = db.Put(utils.GetTestKey(100), utils.GetTestKey(100))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db, err = Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	for i := 0; i <= 100; i++ {
+		val, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+}
+
+func TestDB_RecoverInvalidCRC(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-recover-crc")
+	opts.DirPath = dir
+	opts.MMapAtStartup = false
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	err = db.Put(utils.GetTestKey(1), utils.GetTestKey(1))
+	assert.Nil(t, err)
+	fid, goodSize := db.activeFile.FileId, db.activeFile.WriteOffset
+	assert.Nil(t, db.Close())
+
+	// 长度完整但是内容损坏的数据
+	record, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   createLogRecordKeyWithSeq([]byte("bad-key"), nonTxnSeqNo),
+		Value: []byte("bad-value"),
+		Type:  data.LogRecordNormal,
+	})
+	record[len(record)-1] ^= 0xff
+	appendToActiveFile(t, dir, fid, record)
+
+	// StrictRecovery时和之前一样直接返回错误
+	opts.StrictRecovery = true
+	_, err = Open(opts)
+	assert.Equal(t, data.ErrInvalidCRC, err)
+
+	opts.StrictRecovery = false
+	db, err = Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Equal(t, goodSize, db.activeFile.WriteOffset)
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(1), val)
+	_, err = db.Get([]byte("bad-key"))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestDB_RecoverMidFileCorruption(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-recover-mid")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	pos := db.index.Get(utils.GetTestKey(50))
+	fid, size := db.activeFile.FileId, db.activeFile.WriteOffset
+	assert.Nil(t, db.Close())
+
+	// 中间的一条数据损坏，后面还有有效的数据，不能截断
+	f, err := os.OpenFile(data.GetDatafilePath(dir, fid), os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte{0xff}, pos.Offset+int64(pos.Size)-1)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	_, err = Open(opts)
+	assert.Equal(t, ErrDataDirectoryCorrupted, err)
+	stat, err := os.Stat(data.GetDatafilePath(dir, fid))
+	assert.Nil(t, err)
+	assert.Equal(t, size, stat.Size())
+	assert.Nil(t, os.RemoveAll(dir))
+}
+
+func TestDB_RecoverTornWriteBPlusTree(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-recover-bptree")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	fid, goodSize := db.activeFile.FileId, db.activeFile.WriteOffset
+	assert.Nil(t, db.Close())
+
+	record, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   createLogRecordKeyWithSeq([]byte("torn-key"), nonTxnSeqNo),
+		Value: utils.RandomValue(128),
+	})
+	appendToActiveFile(t, dir, fid, record[:len(record)/2])
+
+	db, err = Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Equal(t, goodSize, db.activeFile.WriteOffset)
+	assert.Nil(t, db.Put(utils.GetTestKey(100), utils.GetTestKey(100)))
+	val, err := db.Get(utils.GetTestKey(100))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(100), val)
+}
+
+func TestDB_RecoverReadOnly(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-recover-readonly")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	assert.Nil(t, db.Sync())
+
+	// 写入的进程正在追加一条数据
+	record, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   createLogRecordKeyWithSeq([]byte("writing-key"), nonTxnSeqNo),
+		Value: utils.RandomValue(128),
+	})
+	record[len(record)-1] ^= 0xff
+	appendToActiveFile(t, dir, db.activeFile.FileId, record)
+	stat, err := os.Stat(data.GetDatafilePath(dir, db.activeFile.FileId))
+	assert.Nil(t, err)
+
+	readOpts := opts
+	readOpts.ReadOnly = true
+	readDB, err := Open(readOpts)
+	assert.Nil(t, err)
+	defer readDB.Close()
+	assert.Equal(t, 100, len(readDB.ListKeys()))
+
+	// 只读模式不截断文件
+	stat2, err := os.Stat(data.GetDatafilePath(dir, db.activeFile.FileId))
+	assert.Nil(t, err)
+	assert.Equal(t, stat.Size(), stat2.Size())
+}
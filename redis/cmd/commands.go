@@ -0,0 +1,615 @@
+package main
+
+import (
+	"errors"
+	kv_go "kv-go"
+	"kv-go/redis"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errSyntax     = errors.New("syntax error")
+	errNotInteger = errors.New("value is not an integer or out of range")
+	errNotFloat   = errors.New("value is not a valid float")
+	errMinMax     = errors.New("min or max is not a float")
+)
+
+// scan默认每次遍历的key数量
+const defaultScanCount = 10
+
+type cmdHandler func(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error
+
+type command struct {
+	handler cmdHandler
+	// 包括命令名在内的参数个数，负数代表最少需要的参数个数，和redis的COMMAND返回的arity一致
+	arity int
+}
+
+var supportedCommands = map[string]command{
+	"ping":    {ping, -1},
+	"command": {commandInfo, -1},
+	"select":  {selectDB, 2},
+
+	// 通用命令
+	"del":    {del, -2},
+	"exists": {exists, -2},
+	"expire": {expire, 3},
+	"type":   {keyType, 2},
+	"scan":   {scan, -2},
+	"dbsize": {dbSize, 1},
+
+	// 字符串
+	"set":  {set, -3},
+	"get":  {get, 2},
+	"mset": {mset, -3},
+
+	// hash
+	"hset":    {hset, -4},
+	"hget":    {hget, 3},
+	"hdel":    {hdel, -3},
+	"hgetall": {hgetall, 2},
+	"hlen":    {hlen, 2},
+
+	// zset
+	"zadd":          {zadd, -4},
+	"zscore":        {zscore, 3},
+	"zrem":          {zrem, -3},
+	"zrangebyscore": {zrangebyscore, -4},
+	"zrank":         {zrank, 3},
+	"zcard":         {zcard, 2},
+
+	// list
+	"lpush":  {lpush, -3},
+	"rpush":  {rpush, -3},
+	"lpop":   {lpop, 2},
+	"rpop":   {rpop, 2},
+	"lindex": {lindex, 3},
+	"lrange": {lrange, 4},
+	"llen":   {llen, 2},
+
+	// set
+	"sadd":      {sadd, -3},
+	"sismember": {sismember, 3},
+	"smembers":  {smembers, 2},
+	"srem":      {srem, -3},
+	"scard":     {scard, 2},
+}
+
+func ping(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	if len(args) > 0 {
+		writer.writeBulk(args[0])
+		return nil
+	}
+	writer.writeString("PONG")
+	return nil
+}
+
+// redis-cli连接时会发送COMMAND DOCS，返回空数组即可
+func commandInfo(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	writer.writeArray(0)
+	return nil
+}
+
+// 只有一个db
+func selectDB(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	if string(args[0]) != "0" {
+		return errors.New("DB index is out of range")
+	}
+	writer.writeString("OK")
+	return nil
+}
+
+func del(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	var count int64
+	for _, key := range args {
+		ok, err := rds.Del(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			count++
+		}
+	}
+	writer.writeInt(count)
+	return nil
+}
+
+func exists(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	var count int64
+	for _, key := range args {
+		ok, err := rds.Exists(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			count++
+		}
+	}
+	writer.writeInt(count)
+	return nil
+}
+
+func expire(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	seconds, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	ok, err := rds.Expire(args[0], time.Duration(seconds)*time.Second)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(boolToInt(ok))
+	return nil
+}
+
+var typeNames = map[byte]string{
+	redis.String: "string",
+	redis.Hash:   "hash",
+	redis.ZSet:   "zset",
+	redis.List:   "list",
+	redis.Set:    "set",
+}
+
+func keyType(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	dataType, err := rds.Type(args[0])
+	if err == kv_go.ErrKeyNotFound {
+		writer.writeString("none")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	writer.writeString(typeNames[dataType])
+	return nil
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+func scan(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return errors.New("invalid cursor")
+	}
+	pattern := ""
+	count := defaultScanCount
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return err
+			}
+			if n < 1 {
+				return errSyntax
+			}
+			count = int(n)
+		default:
+			return errSyntax
+		}
+	}
+
+	keys, next, err := rds.Scan(cursor, pattern, count)
+	if err != nil {
+		return err
+	}
+	writer.writeArray(2)
+	writer.writeBulk([]byte(strconv.FormatUint(next, 10)))
+	writeBulks(writer, keys)
+	return nil
+}
+
+func dbSize(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	writer.writeInt(int64(rds.DBSize()))
+	return nil
+}
+
+// SET key value [EX seconds | PX milliseconds]
+func set(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	var ttl time.Duration
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		n, err := parseInt(args[i+1])
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return errors.New("invalid expire time in 'set' command")
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "ex":
+			ttl = time.Duration(n) * time.Second
+		case "px":
+			ttl = time.Duration(n) * time.Millisecond
+		default:
+			return errSyntax
+		}
+	}
+
+	if err := rds.Set(args[0], ttl, args[1]); err != nil {
+		return err
+	}
+	writer.writeString("OK")
+	return nil
+}
+
+func get(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	value, err := rds.Get(args[0])
+	return writeBulkOrNull(writer, value, err)
+}
+
+func mset(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	if len(args)%2 != 0 {
+		return errors.New("wrong number of arguments for 'mset' command")
+	}
+	if err := rds.MSet(args...); err != nil {
+		return err
+	}
+	writer.writeString("OK")
+	return nil
+}
+
+func hset(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	if len(args)%2 != 1 {
+		return errors.New("wrong number of arguments for 'hset' command")
+	}
+	added, err := rds.HSet(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(added))
+	return nil
+}
+
+func hget(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	value, err := rds.HGet(args[0], args[1])
+	return writeBulkOrNull(writer, value, err)
+}
+
+func hdel(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	deleted, err := rds.HDel(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(deleted))
+	return nil
+}
+
+func hgetall(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	fieldValues, err := rds.HGetAll(args[0])
+	if err != nil {
+		return err
+	}
+	writeBulks(writer, fieldValues)
+	return nil
+}
+
+func hlen(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	size, err := rds.HLen(args[0])
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(size))
+	return nil
+}
+
+// ZADD key score member [score member ...]
+func zadd(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	if len(args)%2 != 1 {
+		return errSyntax
+	}
+	scores := make([]float64, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		score, err := parseFloat(args[i])
+		if err != nil {
+			return err
+		}
+		scores = append(scores, score)
+	}
+
+	var added int64
+	for i, score := range scores {
+		ok, err := rds.ZAdd(args[0], score, args[2*i+2])
+		if err != nil {
+			return err
+		}
+		added += boolToInt(ok)
+	}
+	writer.writeInt(added)
+	return nil
+}
+
+func zscore(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	score, err := rds.ZScore(args[0], args[1])
+	if err == kv_go.ErrKeyNotFound {
+		writer.writeNull()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	writer.writeBulk(formatFloat(score))
+	return nil
+}
+
+func zrem(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	deleted, err := rds.ZRem(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(deleted))
+	return nil
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+// min和max前面加上(代表不包括这个值
+func zrangebyscore(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	min, err := parseScoreBound(args[1], math.Inf(1))
+	if err != nil {
+		return err
+	}
+	max, err := parseScoreBound(args[2], math.Inf(-1))
+	if err != nil {
+		return err
+	}
+
+	withScores := false
+	offset, count := 0, -1
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			o, err := parseInt(args[i+1])
+			if err != nil {
+				return err
+			}
+			c, err := parseInt(args[i+2])
+			if err != nil {
+				return err
+			}
+			offset, count = int(o), int(c)
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+
+	members, err := rds.ZRangeByScore(args[0], min, max)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset >= len(members) {
+		members = nil
+	} else {
+		members = members[offset:]
+	}
+	// count为负数时返回offset之后的所有成员
+	if count >= 0 && count < len(members) {
+		members = members[:count]
+	}
+
+	if withScores {
+		writer.writeArray(len(members) * 2)
+	} else {
+		writer.writeArray(len(members))
+	}
+	for _, member := range members {
+		writer.writeBulk(member.Member)
+		if withScores {
+			writer.writeBulk(formatFloat(member.Score))
+		}
+	}
+	return nil
+}
+
+func zrank(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	rank, err := rds.ZRank(args[0], args[1])
+	if err == kv_go.ErrKeyNotFound {
+		writer.writeNull()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(rank))
+	return nil
+}
+
+func zcard(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	size, err := rds.ZCard(args[0])
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(size))
+	return nil
+}
+
+func lpush(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	size, err := rds.LPush(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(size))
+	return nil
+}
+
+func rpush(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	size, err := rds.RPush(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(size))
+	return nil
+}
+
+func lpop(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	element, err := rds.LPop(args[0])
+	return writeBulkOrNull(writer, element, err)
+}
+
+func rpop(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	element, err := rds.RPop(args[0])
+	return writeBulkOrNull(writer, element, err)
+}
+
+func lindex(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	index, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	element, err := rds.LIndex(args[0], index)
+	return writeBulkOrNull(writer, element, err)
+}
+
+func lrange(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	start, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	stop, err := parseInt(args[2])
+	if err != nil {
+		return err
+	}
+	elements, err := rds.LRange(args[0], start, stop)
+	if err != nil {
+		return err
+	}
+	writeBulks(writer, elements)
+	return nil
+}
+
+func llen(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	size, err := rds.LLen(args[0])
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(size))
+	return nil
+}
+
+func sadd(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	added, err := rds.SAdd(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(added))
+	return nil
+}
+
+func sismember(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	ok, err := rds.SIsMember(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	writer.writeInt(boolToInt(ok))
+	return nil
+}
+
+func smembers(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	members, err := rds.SMembers(args[0])
+	if err != nil {
+		return err
+	}
+	writeBulks(writer, members)
+	return nil
+}
+
+func srem(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	deleted, err := rds.SRem(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(deleted))
+	return nil
+}
+
+func scard(rds *redis.RedisDataStructure, writer respWriter, args [][]byte) error {
+	size, err := rds.SCard(args[0])
+	if err != nil {
+		return err
+	}
+	writer.writeInt(int64(size))
+	return nil
+}
+
+// 解析score的范围，(开头时不包括这个值，向inf的方向取下一个float
+func parseScoreBound(b []byte, inf float64) (float64, error) {
+	exclusive := len(b) > 0 && b[0] == '('
+	if exclusive {
+		b = b[1:]
+	}
+	score, err := parseFloat(b)
+	if err != nil {
+		return 0, errMinMax
+	}
+	if exclusive {
+		score = math.Nextafter(score, inf)
+	}
+	return score, nil
+}
+
+func parseFloat(b []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+// 和redis一样，无穷大返回inf和-inf
+func formatFloat(f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return []byte("inf")
+	case math.IsInf(f, -1):
+		return []byte("-inf")
+	}
+	return []byte(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// 数据不存在时回复null
+func writeBulkOrNull(writer respWriter, value []byte, err error) error {
+	if err == kv_go.ErrKeyNotFound {
+		writer.writeNull()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	writer.writeBulk(value)
+	return nil
+}
+
+func writeBulks(writer respWriter, values [][]byte) {
+	writer.writeArray(len(values))
+	for _, value := range values {
+		writer.writeBulk(value)
+	}
+}
+
+func parseInt(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
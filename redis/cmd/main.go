@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	kv_go "kv-go"
+	"kv-go/redis"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+var (
+	addr    = flag.String("addr", "127.0.0.1:6380", "redis listen address")
+	dirPath = flag.String("dir", filepath.Join(os.TempDir(), "kv-go-redis"), "data directory of the db")
+)
+
+func main() {
+	flag.Parse()
+
+	options := kv_go.DefaultConfig
+	options.DirPath = *dirPath
+	rds, err := redis.NewRedisDataStructure(options)
+	if err != nil {
+		panic(fmt.Sprintf("failed to open db %v", err))
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		_ = rds.Close()
+		panic(fmt.Sprintf("failed to listen %v", err))
+	}
+
+	svr := NewServer(rds)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		_ = svr.Close()
+	}()
+
+	log.Printf("kv-go redis server is listening on %s\n", listener.Addr())
+	if err := svr.Serve(listener); err != nil {
+		log.Printf("failed to serve %v\n", err)
+	}
+	// 关闭db，释放文件锁
+	if err := rds.Close(); err != nil {
+		log.Printf("failed to close db %v\n", err)
+	}
+}
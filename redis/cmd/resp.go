@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// RESP2协议的读写
+// 客户端发送的命令是bulk string的数组，也支持redis-cli和telnet使用的inline命令（空格分隔的一行）
+
+var (
+	errProtocol = errors.New("ERR Protocol error")
+)
+
+// 单个bulk string的最大长度，和redis的proto-max-bulk-len默认值相同
+const maxBulkLen = 512 * 1024 * 1024
+
+// 数组的最大长度
+const maxMultiBulkLen = 1024 * 1024
+
+// 读取一条命令
+func readCommand(reader *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxMultiBulkLen {
+		return nil, errProtocol
+	}
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errProtocol
+		}
+		// 数据之后还有\r\n
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, errProtocol
+		}
+		args = append(args, buf[:size])
+	}
+	return args, nil
+}
+
+// 读取以\r\n结尾的一行，返回的数据不包括\r\n
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// 回复客户端
+type respWriter struct {
+	*bufio.Writer
+}
+
+func (w respWriter) writeString(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w respWriter) writeError(s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func (w respWriter) writeInt(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w respWriter) writeBulk(b []byte) {
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func (w respWriter) writeNull() {
+	w.WriteString("$-1\r\n")
+}
+
+// 写入数组的长度，之后需要写入n个元素
+func (w respWriter) writeArray(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"kv-go/redis"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// 使用RESP2协议的tcp服务，redis-cli和redis客户端可以直接连接
+type Server struct {
+	rds      *redis.RedisDataStructure
+	listener net.Listener
+	mu       *sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+func NewServer(rds *redis.RedisDataStructure) *Server {
+	return &Server{
+		rds:   rds,
+		mu:    new(sync.Mutex),
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// 接收连接，直到Close被调用
+func (svr *Server) Serve(listener net.Listener) error {
+	svr.mu.Lock()
+	svr.listener = listener
+	svr.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			svr.mu.Lock()
+			closed := svr.closed
+			svr.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		svr.mu.Lock()
+		svr.conns[conn] = struct{}{}
+		svr.mu.Unlock()
+		go svr.handleConn(conn)
+	}
+}
+
+// 关闭监听和所有连接，不关闭db
+func (svr *Server) Close() error {
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+	svr.closed = true
+	for conn := range svr.conns {
+		_ = conn.Close()
+	}
+	if svr.listener == nil {
+		return nil
+	}
+	return svr.listener.Close()
+}
+
+func (svr *Server) handleConn(conn net.Conn) {
+	defer func() {
+		svr.mu.Lock()
+		delete(svr.conns, conn)
+		svr.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := respWriter{bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if err == errProtocol {
+				writer.writeError(err.Error())
+				_ = writer.Flush()
+			} else if err != io.EOF {
+				log.Printf("failed to read command from %s: %v\n", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := svr.execCommand(writer, args)
+		// 客户端一次发送了多条命令时，全部执行完之后再一起回复
+		if quit || reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// 执行命令，返回是否需要关闭连接
+func (svr *Server) execCommand(writer respWriter, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	switch name {
+	case "quit":
+		writer.writeString("OK")
+		return true
+	}
+
+	cmd, ok := supportedCommands[name]
+	if !ok {
+		writer.writeError("ERR unknown command '" + string(args[0]) + "'")
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		writer.writeError("ERR wrong number of arguments for '" + name + "' command")
+		return false
+	}
+
+	if err := cmd.handler(svr.rds, writer, args[1:]); err != nil {
+		if err == redis.ErrWrongTypeOperation {
+			writer.writeError(err.Error())
+		} else {
+			writer.writeError("ERR " + err.Error())
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	kv_go "kv-go"
+	"kv-go/redis"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startTestServer(t *testing.T) net.Conn {
+	opts := kv_go.DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-redis-server")
+	opts.DirPath = dir
+	rds, err := redis.NewRedisDataStructure(opts)
+	assert.Nil(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	svr := NewServer(rds)
+	go func() {
+		_ = svr.Serve(listener)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = svr.Close()
+		_ = rds.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	conn := startTestServer(t)
+	reader := bufio.NewReader(conn)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{"*1\r\n$4\r\nPING\r\n", "+PONG\r\n"},
+		{"*3\r\n$3\r\nSET\r\n$2\r\nk1\r\n$2\r\nv1\r\n", "+OK\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$2\r\nk1\r\n", "$2\r\nv1\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$2\r\nk2\r\n", "$-1\r\n"},
+		{"*5\r\n$4\r\nMSET\r\n$2\r\nk2\r\n$2\r\nv2\r\n$2\r\nk3\r\n$0\r\n\r\n", "+OK\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$2\r\nk3\r\n", "$0\r\n\r\n"},
+		{"*4\r\n$6\r\nEXISTS\r\n$2\r\nk1\r\n$2\r\nk2\r\n$2\r\nk9\r\n", ":2\r\n"},
+		{"*1\r\n$6\r\nDBSIZE\r\n", ":3\r\n"},
+		{"*4\r\n$4\r\nSCAN\r\n$1\r\n0\r\n$5\r\nMATCH\r\n$2\r\nk?\r\n", "*2\r\n$1\r\n0\r\n*3\r\n$2\r\nk1\r\n$2\r\nk2\r\n$2\r\nk3\r\n"},
+		{"*3\r\n$6\r\nEXPIRE\r\n$2\r\nk1\r\n$3\r\n100\r\n", ":1\r\n"},
+		{"*3\r\n$6\r\nEXPIRE\r\n$2\r\nk9\r\n$3\r\n100\r\n", ":0\r\n"},
+		{"*3\r\n$3\r\nDEL\r\n$2\r\nk1\r\n$2\r\nk9\r\n", ":1\r\n"},
+		{"*2\r\n$4\r\nTYPE\r\n$2\r\nk2\r\n", "+string\r\n"},
+		{"*1\r\n$3\r\nGET\r\n", "-ERR wrong number of arguments for 'get' command\r\n"},
+		{"*1\r\n$7\r\nUNKNOWN\r\n", "-ERR unknown command 'UNKNOWN'\r\n"},
+		{"*6\r\n$4\r\nHSET\r\n$1\r\nh\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n", ":2\r\n"},
+		{"*3\r\n$4\r\nHGET\r\n$1\r\nh\r\n$1\r\nb\r\n", "$1\r\n2\r\n"},
+		{"*2\r\n$7\r\nHGETALL\r\n$1\r\nh\r\n", "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"*3\r\n$4\r\nHDEL\r\n$1\r\nh\r\n$1\r\na\r\n", ":1\r\n"},
+		{"*2\r\n$4\r\nHLEN\r\n$1\r\nh\r\n", ":1\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$1\r\nh\r\n", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"*8\r\n$4\r\nZADD\r\n$1\r\nz\r\n$1\r\n1\r\n$1\r\na\r\n$3\r\n2.5\r\n$1\r\nb\r\n$1\r\n(\r\n$1\r\nc\r\n", "-ERR value is not a valid float\r\n"},
+		{"*8\r\n$4\r\nZADD\r\n$1\r\nz\r\n$1\r\n1\r\n$1\r\na\r\n$3\r\n2.5\r\n$1\r\nb\r\n$1\r\n3\r\n$1\r\nc\r\n", ":3\r\n"},
+		{"*3\r\n$6\r\nZSCORE\r\n$1\r\nz\r\n$1\r\nb\r\n", "$3\r\n2.5\r\n"},
+		{"*5\r\n$13\r\nZRANGEBYSCORE\r\n$1\r\nz\r\n$2\r\n(1\r\n$4\r\n+inf\r\n$10\r\nWITHSCORES\r\n", "*4\r\n$1\r\nb\r\n$3\r\n2.5\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{"*7\r\n$13\r\nZRANGEBYSCORE\r\n$1\r\nz\r\n$4\r\n-inf\r\n$2\r\n(3\r\n$5\r\nLIMIT\r\n$1\r\n1\r\n$1\r\n5\r\n", "*1\r\n$1\r\nb\r\n"},
+		{"*3\r\n$5\r\nZRANK\r\n$1\r\nz\r\n$1\r\nc\r\n", ":2\r\n"},
+		{"*3\r\n$4\r\nZREM\r\n$1\r\nz\r\n$1\r\na\r\n", ":1\r\n"},
+		{"*2\r\n$5\r\nZCARD\r\n$1\r\nz\r\n", ":2\r\n"},
+		{"*4\r\n$5\r\nRPUSH\r\n$1\r\nl\r\n$1\r\nb\r\n$1\r\nc\r\n", ":2\r\n"},
+		{"*3\r\n$5\r\nLPUSH\r\n$1\r\nl\r\n$1\r\na\r\n", ":3\r\n"},
+		{"*4\r\n$6\r\nLRANGE\r\n$1\r\nl\r\n$1\r\n0\r\n$2\r\n-1\r\n", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"*3\r\n$6\r\nLINDEX\r\n$1\r\nl\r\n$2\r\n-1\r\n", "$1\r\nc\r\n"},
+		{"*2\r\n$4\r\nLPOP\r\n$1\r\nl\r\n", "$1\r\na\r\n"},
+		{"*2\r\n$4\r\nRPOP\r\n$1\r\nl\r\n", "$1\r\nc\r\n"},
+		{"*2\r\n$4\r\nLLEN\r\n$1\r\nl\r\n", ":1\r\n"},
+		{"*2\r\n$4\r\nLPOP\r\n$5\r\nempty\r\n", "$-1\r\n"},
+		{"*5\r\n$4\r\nSADD\r\n$1\r\ns\r\n$1\r\nb\r\n$1\r\na\r\n$1\r\nb\r\n", ":2\r\n"},
+		{"*3\r\n$9\r\nSISMEMBER\r\n$1\r\ns\r\n$1\r\na\r\n", ":1\r\n"},
+		{"*2\r\n$8\r\nSMEMBERS\r\n$1\r\ns\r\n", "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"*3\r\n$4\r\nSREM\r\n$1\r\ns\r\n$1\r\na\r\n", ":1\r\n"},
+		{"*2\r\n$5\r\nSCARD\r\n$1\r\ns\r\n", ":1\r\n"},
+		{"*2\r\n$4\r\nTYPE\r\n$1\r\ns\r\n", "+set\r\n"},
+		// inline命令
+		{"GET k2\r\n", "$2\r\nv2\r\n"},
+	}
+	for _, tt := range tests {
+		_, err := conn.Write([]byte(tt.request))
+		assert.Nil(t, err)
+		buf := make([]byte, len(tt.response))
+		_, err = io.ReadFull(reader, buf)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.response, string(buf), tt.request)
+	}
+
+	// 一次发送多条命令
+	_, err := conn.Write([]byte("*1\r\n$4\r\nPING\r\n*2\r\n$4\r\nPING\r\n$2\r\nhi\r\n"))
+	assert.Nil(t, err)
+	expected := "+PONG\r\n$2\r\nhi\r\n"
+	buf := make([]byte, len(expected))
+	_, err = io.ReadFull(reader, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(buf))
+}
@@ -0,0 +1,199 @@
+package redis
+
+import (
+	kv_go "kv-go"
+	"time"
+)
+
+// ============= 通用命令 =============
+
+// 删除key，返回key是否存在
+// 只删除元数据，不需要遍历数据结构的所有元素
+// 重新创建key时使用新的版本号，旧版本的内部key不会再被访问
+func (rds *RedisDataStructure) Del(key []byte) (bool, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+	return rds.del(key)
+}
+
+func (rds *RedisDataStructure) del(key []byte) (bool, error) {
+	exists, err := rds.Exists(key)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := rds.db.Delete(metaKey(key)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (rds *RedisDataStructure) Exists(key []byte) (bool, error) {
+	_, err := rds.db.Get(metaKey(key))
+	if err == kv_go.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (rds *RedisDataStructure) Type(key []byte) (redisDataType, error) {
+	buf, err := rds.db.Get(metaKey(key))
+	if err != nil {
+		return 0, err
+	}
+	dataType, _, _ := decodeValueHeader(buf)
+	return dataType, nil
+}
+
+// 设置key的过期时间，ttl <= 0 时直接删除key，返回key是否存在
+func (rds *RedisDataStructure) Expire(key []byte, ttl time.Duration) (bool, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	buf, err := rds.db.Get(metaKey(key))
+	if err == kv_go.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ttl <= 0 {
+		return rds.del(key)
+	}
+
+	// 重新写入元数据，更新头部中的过期时间
+	dataType, _, payload := decodeValueHeader(buf)
+	value := append(encodeValueHeader(dataType, ttlToExpire(ttl)), payload...)
+	if err := rds.db.PutWithTTL(metaKey(key), value, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// key的数量
+// db中还有数据结构的内部key，Stat.KeyNum也包含已经过期的key，不等于key的数量
+// 需要遍历元数据key，迭代器会跳过过期的key
+func (rds *RedisDataStructure) DBSize() uint {
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = []byte{metaKeyPrefix}
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	var size uint
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		size++
+	}
+	return size
+}
+
+// 从cursor开始遍历count个key，返回匹配pattern的key和下一次遍历的cursor，cursor为0时遍历结束
+// cursor是已经遍历过的key的数量，遍历期间删除了前面的key，可能会漏掉一些key
+func (rds *RedisDataStructure) Scan(cursor uint64, pattern string, count int) ([][]byte, uint64, error) {
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = []byte{metaKeyPrefix}
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	var keys [][]byte
+	var offset uint64
+	var scanned int
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		if offset < cursor {
+			offset++
+			continue
+		}
+		if scanned == count {
+			return keys, cursor + uint64(scanned), nil
+		}
+		scanned++
+
+		key := iter.Key()[1:]
+		if pattern == "" || pattern == "*" || matchPattern([]byte(pattern), key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, 0, nil
+}
+
+// redis的glob匹配规则：* 匹配任意字符串，? 匹配任意一个字符，[abc] [^a] [a-z] 匹配字符集合，\ 转义
+func matchPattern(pattern, str []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// 连续的*和一个*相同
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if matchPattern(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) > 1 {
+					pattern = pattern[1:]
+					if pattern[0] == str[0] {
+						match = true
+					}
+					pattern = pattern[1:]
+				} else if len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']' {
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					if str[0] >= start && str[0] <= end {
+						match = true
+					}
+					pattern = pattern[3:]
+				} else {
+					if pattern[0] == str[0] {
+						match = true
+					}
+					pattern = pattern[1:]
+				}
+			}
+			// 跳过]
+			if len(pattern) > 0 {
+				pattern = pattern[1:]
+			}
+			if match == not {
+				return false
+			}
+			str = str[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(str) == 0
+}
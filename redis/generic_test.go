@@ -0,0 +1,129 @@
+package redis
+
+import (
+	kv_go "kv-go"
+	"kv-go/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisDataStructure_Del_Type(t *testing.T) {
+	rds := openTestRds(t)
+
+	// del
+	ok, err := rds.Del(utils.GetTestKey(11))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	err = rds.Set(utils.GetTestKey(1), 0, utils.RandomValue(100))
+	assert.Nil(t, err)
+
+	// type
+	typ, err := rds.Type(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, String, typ)
+
+	ok, err = rds.Del(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	_, err = rds.Get(utils.GetTestKey(1))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+	ok, err = rds.Exists(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedisDataStructure_Expire(t *testing.T) {
+	rds := openTestRds(t)
+
+	ok, err := rds.Expire([]byte("not-exist"), time.Second)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	err = rds.Set([]byte("k1"), 0, []byte("v1"))
+	assert.Nil(t, err)
+	ok, err = rds.Expire([]byte("k1"), time.Millisecond*10)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// 过期之前value不变
+	val, err := rds.Get([]byte("k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), val)
+
+	time.Sleep(time.Millisecond * 20)
+	ok, err = rds.Exists([]byte("k1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	// 过期的key不计入DBSIZE
+	assert.Equal(t, uint(0), rds.DBSize())
+
+	// ttl <= 0 直接删除
+	err = rds.Set([]byte("k2"), 0, []byte("v2"))
+	assert.Nil(t, err)
+	ok, err = rds.Expire([]byte("k2"), 0)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = rds.Get([]byte("k2"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+}
+
+func TestRedisDataStructure_Scan(t *testing.T) {
+	rds := openTestRds(t)
+
+	for i := 0; i < 25; i++ {
+		err := rds.Set(utils.GetTestKey(i), 0, utils.RandomValue(10))
+		assert.Nil(t, err)
+	}
+	err := rds.Set([]byte("other"), 0, []byte("v"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(26), rds.DBSize())
+
+	// 分多次遍历所有key
+	var all [][]byte
+	var cursor uint64
+	for {
+		keys, next, err := rds.Scan(cursor, "", 10)
+		assert.Nil(t, err)
+		all = append(all, keys...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, 26, len(all))
+	assert.Equal(t, utils.GetTestKey(0), all[0])
+
+	keys, next, err := rds.Scan(0, "oth*", 100)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), next)
+	assert.Equal(t, [][]byte{[]byte("other")}, keys)
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		match   bool
+	}{
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"user:*:name", "user:1:name", true},
+		{"user:*:name", "user:1:age", false},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, matchPattern([]byte(tt.pattern), []byte(tt.str)), tt.pattern+" "+tt.str)
+	}
+}
@@ -0,0 +1,152 @@
+package redis
+
+import (
+	kv_go "kv-go"
+)
+
+// ============= Hash =============
+// 元数据key中记录版本号和字段数量，每个字段是一个内部key：前缀 + key + 版本号 + field，value是字段的值
+
+func hashFieldKey(key []byte, version int64, field []byte) []byte {
+	return append(dataKeyPrefixOf(key, version), field...)
+}
+
+// 写入多个字段，fieldValues为field value交替的数组，返回新增的字段数量
+// 所有字段和元数据在一个WriteBatch中写入
+func (rds *RedisDataStructure) HSet(key []byte, fieldValues ...[]byte) (int, error) {
+	if len(fieldValues) == 0 || len(fieldValues)%2 != 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, Hash)
+	if err != nil {
+		return 0, err
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	// 同一次写入中重复的字段只算一次
+	added := make(map[string]struct{})
+	for i := 0; i < len(fieldValues); i += 2 {
+		fieldKey := hashFieldKey(key, md.version, fieldValues[i])
+		if _, ok := added[string(fieldKey)]; !ok {
+			if _, err := rds.db.Get(fieldKey); err == kv_go.ErrKeyNotFound {
+				added[string(fieldKey)] = struct{}{}
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		if err := wb.Put(fieldKey, fieldValues[i+1]); err != nil {
+			return 0, err
+		}
+	}
+
+	md.size += uint32(len(added))
+	if err := wb.PutWithTTL(metaKey(key), md.encode(), md.ttl()); err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(added), nil
+}
+
+func (rds *RedisDataStructure) HGet(key, field []byte) ([]byte, error) {
+	md, err := rds.findMetadata(key, Hash)
+	if err != nil {
+		return nil, err
+	}
+	if md.size == 0 {
+		return nil, kv_go.ErrKeyNotFound
+	}
+	return rds.db.Get(hashFieldKey(key, md.version, field))
+}
+
+// 删除多个字段，返回删除的字段数量，字段全部删除之后key也被删除
+func (rds *RedisDataStructure) HDel(key []byte, fields ...[]byte) (int, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, Hash)
+	if err != nil {
+		return 0, err
+	}
+	if md.size == 0 {
+		return 0, nil
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	deleted := make(map[string]struct{})
+	for _, field := range fields {
+		fieldKey := hashFieldKey(key, md.version, field)
+		if _, ok := deleted[string(fieldKey)]; ok {
+			continue
+		}
+		if _, err := rds.db.Get(fieldKey); err == kv_go.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		deleted[string(fieldKey)] = struct{}{}
+		if err := wb.Delete(fieldKey); err != nil {
+			return 0, err
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	md.size -= uint32(len(deleted))
+	if md.size == 0 {
+		err = wb.Delete(metaKey(key))
+	} else {
+		err = wb.PutWithTTL(metaKey(key), md.encode(), md.ttl())
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
+// 返回所有的字段和值，field value交替排列，字段按照字节序排序
+func (rds *RedisDataStructure) HGetAll(key []byte) ([][]byte, error) {
+	md, err := rds.findMetadata(key, Hash)
+	if err != nil {
+		return nil, err
+	}
+	if md.size == 0 {
+		return nil, nil
+	}
+
+	prefix := dataKeyPrefixOf(key, md.version)
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = prefix
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	result := make([][]byte, 0, md.size*2)
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		value, err := iter.Value()
+		if err == kv_go.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, iter.Key()[len(prefix):], value)
+	}
+	return result, nil
+}
+
+func (rds *RedisDataStructure) HLen(key []byte) (uint32, error) {
+	md, err := rds.findMetadata(key, Hash)
+	if err != nil {
+		return 0, err
+	}
+	return md.size, nil
+}
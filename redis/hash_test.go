@@ -0,0 +1,127 @@
+package redis
+
+import (
+	kv_go "kv-go"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisDataStructure_HSet_HGet(t *testing.T) {
+	rds := openTestRds(t)
+
+	added, err := rds.HSet([]byte("user"), []byte("name"), []byte("alice"), []byte("age"), []byte("20"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, added)
+
+	// 更新已有的字段不算新增，同一次写入中重复的字段只算一次
+	added, err = rds.HSet([]byte("user"), []byte("age"), []byte("21"), []byte("city"), []byte("a"), []byte("city"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, added)
+
+	val, err := rds.HGet([]byte("user"), []byte("age"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("21"), val)
+	val, err = rds.HGet([]byte("user"), []byte("city"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("b"), val)
+
+	_, err = rds.HGet([]byte("user"), []byte("not-exist"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+	_, err = rds.HGet([]byte("not-exist"), []byte("name"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+
+	size, err := rds.HLen([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), size)
+
+	_, err = rds.HSet([]byte("user"), []byte("name"))
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+
+	// 类型不一致
+	err = rds.Set([]byte("str"), 0, []byte("v"))
+	assert.Nil(t, err)
+	_, err = rds.HSet([]byte("str"), []byte("f"), []byte("v"))
+	assert.Equal(t, ErrWrongTypeOperation, err)
+	_, err = rds.Get([]byte("user"))
+	assert.Equal(t, ErrWrongTypeOperation, err)
+}
+
+func TestRedisDataStructure_HDel(t *testing.T) {
+	rds := openTestRds(t)
+
+	deleted, err := rds.HDel([]byte("user"), []byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, deleted)
+
+	_, err = rds.HSet([]byte("user"), []byte("name"), []byte("alice"), []byte("age"), []byte("20"))
+	assert.Nil(t, err)
+
+	deleted, err = rds.HDel([]byte("user"), []byte("name"), []byte("name"), []byte("not-exist"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	size, err := rds.HLen([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), size)
+
+	// 字段全部删除之后key也被删除
+	deleted, err = rds.HDel([]byte("user"), []byte("age"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	ok, err := rds.Exists([]byte("user"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedisDataStructure_HGetAll(t *testing.T) {
+	rds := openTestRds(t)
+
+	_, err := rds.HSet([]byte("user"), []byte("name"), []byte("alice"), []byte("age"), []byte("20"))
+	assert.Nil(t, err)
+	// 前缀相同的key不会遍历到
+	_, err = rds.HSet([]byte("user1"), []byte("name"), []byte("bob"))
+	assert.Nil(t, err)
+
+	all, err := rds.HGetAll([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("age"), []byte("20"), []byte("name"), []byte("alice")}, all)
+
+	// 删除key之后重新创建，旧的字段不可见
+	ok, err := rds.Del([]byte("user"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = rds.HSet([]byte("user"), []byte("city"), []byte("a"))
+	assert.Nil(t, err)
+
+	all, err = rds.HGetAll([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("city"), []byte("a")}, all)
+	_, err = rds.HGet([]byte("user"), []byte("name"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+
+	// 内部key不计入key的数量
+	assert.Equal(t, uint(2), rds.DBSize())
+}
+
+func TestRedisDataStructure_HSet_KeepTTL(t *testing.T) {
+	rds := openTestRds(t)
+
+	_, err := rds.HSet([]byte("user"), []byte("name"), []byte("alice"))
+	assert.Nil(t, err)
+	ok, err := rds.Expire([]byte("user"), time.Millisecond*50)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// 写入字段不会清除过期时间
+	_, err = rds.HSet([]byte("user"), []byte("age"), []byte("20"))
+	assert.Nil(t, err)
+	time.Sleep(time.Millisecond * 60)
+
+	size, err := rds.HLen([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), size)
+	all, err := rds.HGetAll([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(all))
+}
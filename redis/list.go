@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"encoding/binary"
+	kv_go "kv-go"
+)
+
+// ============= List =============
+// 元数据中记录head和tail，元素的位置在[head, tail)之间
+// 每个元素是一个内部key：前缀 + key + 版本号 + 位置（8个字节），位置的字节序和元素的顺序一致
+// 每次push和pop的元素和元数据在一个WriteBatch中写入，崩溃之后不会出现元数据和元素不一致
+
+func listElementKey(key []byte, version int64, index uint64) []byte {
+	buf := dataKeyPrefixOf(key, version)
+	indexBuf := make([]byte, 8)
+	binary.BigEndian.PutUint64(indexBuf, index)
+	return append(buf, indexBuf...)
+}
+
+// 从头部插入元素，多个元素依次插入，返回插入之后的元素数量
+func (rds *RedisDataStructure) LPush(key []byte, elements ...[]byte) (uint32, error) {
+	return rds.pushInner(key, elements, true)
+}
+
+// 从尾部插入元素，返回插入之后的元素数量
+func (rds *RedisDataStructure) RPush(key []byte, elements ...[]byte) (uint32, error) {
+	return rds.pushInner(key, elements, false)
+}
+
+// 从头部取出一个元素，list为空时返回ErrKeyNotFound
+func (rds *RedisDataStructure) LPop(key []byte) ([]byte, error) {
+	return rds.popInner(key, true)
+}
+
+// 从尾部取出一个元素，list为空时返回ErrKeyNotFound
+func (rds *RedisDataStructure) RPop(key []byte) ([]byte, error) {
+	return rds.popInner(key, false)
+}
+
+func (rds *RedisDataStructure) pushInner(key []byte, elements [][]byte, isLeft bool) (uint32, error) {
+	if len(elements) == 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, List)
+	if err != nil {
+		return 0, err
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	for _, element := range elements {
+		var index uint64
+		if isLeft {
+			md.head--
+			index = md.head
+		} else {
+			index = md.tail
+			md.tail++
+		}
+		if err := wb.Put(listElementKey(key, md.version, index), element); err != nil {
+			return 0, err
+		}
+	}
+
+	md.size += uint32(len(elements))
+	if err := wb.PutWithTTL(metaKey(key), md.encode(), md.ttl()); err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return md.size, nil
+}
+
+func (rds *RedisDataStructure) popInner(key []byte, isLeft bool) ([]byte, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, List)
+	if err != nil {
+		return nil, err
+	}
+	if md.size == 0 {
+		return nil, kv_go.ErrKeyNotFound
+	}
+
+	var index uint64
+	if isLeft {
+		index = md.head
+		md.head++
+	} else {
+		md.tail--
+		index = md.tail
+	}
+	elementKey := listElementKey(key, md.version, index)
+	element, err := rds.db.Get(elementKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	if err := wb.Delete(elementKey); err != nil {
+		return nil, err
+	}
+	md.size--
+	if md.size == 0 {
+		err = wb.Delete(metaKey(key))
+	} else {
+		err = wb.PutWithTTL(metaKey(key), md.encode(), md.ttl())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := wb.Commit(); err != nil {
+		return nil, err
+	}
+	return element, nil
+}
+
+// 返回第index个元素，负数代表从尾部开始数，-1是最后一个元素，超出范围时返回ErrKeyNotFound
+func (rds *RedisDataStructure) LIndex(key []byte, index int64) ([]byte, error) {
+	md, err := rds.findMetadata(key, List)
+	if err != nil {
+		return nil, err
+	}
+
+	if index < 0 {
+		index += int64(md.size)
+	}
+	if index < 0 || index >= int64(md.size) {
+		return nil, kv_go.ErrKeyNotFound
+	}
+	return rds.db.Get(listElementKey(key, md.version, md.head+uint64(index)))
+}
+
+// 返回[start, stop]之间的元素，负数代表从尾部开始数，和redis的LRANGE相同
+func (rds *RedisDataStructure) LRange(key []byte, start, stop int64) ([][]byte, error) {
+	md, err := rds.findMetadata(key, List)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(md.size)
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return nil, nil
+	}
+
+	prefix := dataKeyPrefixOf(key, md.version)
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = prefix
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	elements := make([][]byte, 0, stop-start+1)
+	iter.Seek(listElementKey(key, md.version, md.head+uint64(start)))
+	for i := start; i <= stop && iter.Valid(); i++ {
+		element, err := iter.Value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		iter.Next()
+	}
+	return elements, nil
+}
+
+func (rds *RedisDataStructure) LLen(key []byte) (uint32, error) {
+	md, err := rds.findMetadata(key, List)
+	if err != nil {
+		return 0, err
+	}
+	return md.size, nil
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	kv_go "kv-go"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisDataStructure_LPush_RPush_Pop(t *testing.T) {
+	rds := openTestRds(t)
+
+	size, err := rds.RPush([]byte("queue"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), size)
+	size, err = rds.LPush([]byte("queue"), []byte("a"), []byte("z"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(4), size)
+
+	// z a b c
+	val, err := rds.LPop([]byte("queue"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("z"), val)
+	val, err = rds.RPop([]byte("queue"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("c"), val)
+	size, err = rds.LLen([]byte("queue"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), size)
+
+	val, err = rds.LPop([]byte("queue"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+	val, err = rds.LPop([]byte("queue"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("b"), val)
+
+	// 元素全部取出之后key也被删除
+	_, err = rds.LPop([]byte("queue"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+	_, err = rds.RPop([]byte("queue"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+	ok, err := rds.Exists([]byte("queue"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedisDataStructure_LIndex_LRange(t *testing.T) {
+	rds := openTestRds(t)
+
+	_, err := rds.RPush([]byte("list"), []byte("c"), []byte("d"), []byte("e"))
+	assert.Nil(t, err)
+	_, err = rds.LPush([]byte("list"), []byte("b"), []byte("a"))
+	assert.Nil(t, err)
+
+	val, err := rds.LIndex([]byte("list"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+	val, err = rds.LIndex([]byte("list"), -1)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("e"), val)
+	_, err = rds.LIndex([]byte("list"), 5)
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+	_, err = rds.LIndex([]byte("list"), -6)
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+
+	elements, err := rds.LRange([]byte("list"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}, elements)
+	elements, err = rds.LRange([]byte("list"), 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c")}, elements)
+	elements, err = rds.LRange([]byte("list"), -2, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("d"), []byte("e")}, elements)
+	elements, err = rds.LRange([]byte("list"), 3, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(elements))
+}
+
+func TestRedisDataStructure_List_Reopen(t *testing.T) {
+	opts := kv_go.DefaultConfig
+	opts.DirPath = t.TempDir()
+	rds, err := NewRedisDataStructure(opts)
+	assert.Nil(t, err)
+
+	_, err = rds.RPush([]byte("queue"), []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+	_, err = rds.LPop([]byte("queue"))
+	assert.Nil(t, err)
+	err = rds.Close()
+	assert.Nil(t, err)
+
+	// 重启之后head和tail不变
+	rds, err = NewRedisDataStructure(opts)
+	assert.Nil(t, err)
+	defer rds.Close()
+	_, err = rds.RPush([]byte("queue"), []byte("c"))
+	assert.Nil(t, err)
+	elements, err := rds.LRange([]byte("queue"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c")}, elements)
+}
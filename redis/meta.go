@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"encoding/binary"
+	kv_go "kv-go"
+	"math"
+	"time"
+)
+
+// hash set list zset的元数据
+type metadata struct {
+	dataType redisDataType
+	expire   int64  // 过期时间
+	version  int64  // 版本号，key被删除后重新创建时使用新的版本号，旧版本的内部key不会再被访问
+	size     uint32 // 元素数量
+	head     uint64 // list专用，第一个元素的位置
+	tail     uint64 // list专用，最后一个元素的下一个位置
+}
+
+// list新建时head和tail的位置，从中间开始，两边都可以push
+const initialListMark = math.MaxUint64 / 2
+
+func (md *metadata) encode() []byte {
+	header := encodeValueHeader(md.dataType, md.expire)
+	buf := make([]byte, len(header)+binary.MaxVarintLen64*4)
+	index := copy(buf, header)
+	index += binary.PutUvarint(buf[index:], uint64(md.version))
+	index += binary.PutUvarint(buf[index:], uint64(md.size))
+	if md.dataType == List {
+		index += binary.PutUvarint(buf[index:], md.head)
+		index += binary.PutUvarint(buf[index:], md.tail)
+	}
+	return buf[:index]
+}
+
+func decodeMetadata(buf []byte) *metadata {
+	dataType, expire, buf := decodeValueHeader(buf)
+	version, n := binary.Uvarint(buf)
+	buf = buf[n:]
+	size, n := binary.Uvarint(buf)
+	buf = buf[n:]
+	md := &metadata{
+		dataType: dataType,
+		expire:   expire,
+		version:  int64(version),
+		size:     uint32(size),
+	}
+	if dataType == List {
+		md.head, n = binary.Uvarint(buf)
+		md.tail, _ = binary.Uvarint(buf[n:])
+	}
+	return md
+}
+
+// 查找key的元数据，key不存在时返回一个新的元数据，类型不一致时返回ErrWrongTypeOperation
+func (rds *RedisDataStructure) findMetadata(key []byte, dataType redisDataType) (*metadata, error) {
+	buf, err := rds.db.Get(metaKey(key))
+	if err != nil && err != kv_go.ErrKeyNotFound {
+		return nil, err
+	}
+
+	if err == kv_go.ErrKeyNotFound {
+		md := &metadata{
+			dataType: dataType,
+			version:  time.Now().UnixNano(),
+		}
+		if dataType == List {
+			md.head = initialListMark
+			md.tail = initialListMark
+		}
+		return md, nil
+	}
+
+	md := decodeMetadata(buf)
+	if md.dataType != dataType {
+		return nil, ErrWrongTypeOperation
+	}
+	return md, nil
+}
+
+// 元数据写入db时的ttl，更新数据时保留原来的过期时间
+func (md *metadata) ttl() time.Duration {
+	return expireToTTL(md.expire)
+}
+
+// 内部key：前缀 + key的长度 + key + 版本号，之后是各个数据结构自己的部分
+// 版本号固定8个字节，同一个key同一个版本的内部key有相同的前缀，可以用迭代器遍历
+func dataKeyPrefixOf(key []byte, version int64) []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen64+len(key)+8)
+	buf[0] = dataKeyPrefix
+	index := 1 + binary.PutUvarint(buf[1:], uint64(len(key)))
+	index += copy(buf[index:], key)
+	binary.BigEndian.PutUint64(buf[index:], uint64(version))
+	return buf[:index+8]
+}
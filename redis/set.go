@@ -0,0 +1,168 @@
+package redis
+
+import (
+	kv_go "kv-go"
+)
+
+// ============= Set =============
+// 元数据中记录版本号和成员数量，每个成员是一个内部key：前缀 + key + 版本号 + member，value为空
+
+func setMemberKey(key []byte, version int64, member []byte) []byte {
+	return append(dataKeyPrefixOf(key, version), member...)
+}
+
+// 添加多个成员，返回新增的成员数量
+func (rds *RedisDataStructure) SAdd(key []byte, members ...[]byte) (int, error) {
+	if len(members) == 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, Set)
+	if err != nil {
+		return 0, err
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	added := make(map[string]struct{})
+	for _, member := range members {
+		if _, ok := added[string(member)]; ok {
+			continue
+		}
+		memberKey := setMemberKey(key, md.version, member)
+		if _, err := rds.db.Get(memberKey); err == nil {
+			continue
+		} else if err != kv_go.ErrKeyNotFound {
+			return 0, err
+		}
+		added[string(member)] = struct{}{}
+		if err := wb.Put(memberKey, nil); err != nil {
+			return 0, err
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	md.size += uint32(len(added))
+	if err := wb.PutWithTTL(metaKey(key), md.encode(), md.ttl()); err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(added), nil
+}
+
+func (rds *RedisDataStructure) SIsMember(key, member []byte) (bool, error) {
+	md, err := rds.findMetadata(key, Set)
+	if err != nil {
+		return false, err
+	}
+	if md.size == 0 {
+		return false, nil
+	}
+
+	_, err = rds.db.Get(setMemberKey(key, md.version, member))
+	if err == kv_go.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 删除多个成员，返回删除的成员数量，成员全部删除之后key也被删除
+func (rds *RedisDataStructure) SRem(key []byte, members ...[]byte) (int, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, Set)
+	if err != nil {
+		return 0, err
+	}
+	if md.size == 0 {
+		return 0, nil
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	deleted := make(map[string]struct{})
+	for _, member := range members {
+		if _, ok := deleted[string(member)]; ok {
+			continue
+		}
+		memberKey := setMemberKey(key, md.version, member)
+		if _, err := rds.db.Get(memberKey); err == kv_go.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		deleted[string(member)] = struct{}{}
+		if err := wb.Delete(memberKey); err != nil {
+			return 0, err
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	md.size -= uint32(len(deleted))
+	if md.size == 0 {
+		err = wb.Delete(metaKey(key))
+	} else {
+		err = wb.PutWithTTL(metaKey(key), md.encode(), md.ttl())
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
+// 返回所有成员，按照字节序排序
+// 用set的前缀遍历成员，不需要遍历db中其他的key
+func (rds *RedisDataStructure) SMembers(key []byte) ([][]byte, error) {
+	var members [][]byte
+	err := rds.SScan(key, func(member []byte) bool {
+		members = append(members, member)
+		return true
+	})
+	return members, err
+}
+
+// 依次遍历所有成员，fn返回false时停止遍历，成员很多时不需要一次全部放进内存
+func (rds *RedisDataStructure) SScan(key []byte, fn func(member []byte) bool) error {
+	md, err := rds.findMetadata(key, Set)
+	if err != nil {
+		return err
+	}
+	if md.size == 0 {
+		return nil
+	}
+
+	prefix := dataKeyPrefixOf(key, md.version)
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = prefix
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		if !fn(iter.Key()[len(prefix):]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (rds *RedisDataStructure) SCard(key []byte) (uint32, error) {
+	md, err := rds.findMetadata(key, Set)
+	if err != nil {
+		return 0, err
+	}
+	return md.size, nil
+}
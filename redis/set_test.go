@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisDataStructure_SAdd_SIsMember(t *testing.T) {
+	rds := openTestRds(t)
+
+	added, err := rds.SAdd([]byte("tags"), []byte("go"), []byte("db"), []byte("go"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, added)
+	added, err = rds.SAdd([]byte("tags"), []byte("go"), []byte("kv"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, added)
+
+	ok, err := rds.SIsMember([]byte("tags"), []byte("kv"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.SIsMember([]byte("tags"), []byte("not-exist"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = rds.SIsMember([]byte("not-exist"), []byte("go"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	size, err := rds.SCard([]byte("tags"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), size)
+
+	_, err = rds.SAdd([]byte("tags"))
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+}
+
+func TestRedisDataStructure_SRem_SMembers(t *testing.T) {
+	rds := openTestRds(t)
+
+	_, err := rds.SAdd([]byte("tags"), []byte("go"), []byte("db"), []byte("kv"))
+	assert.Nil(t, err)
+	// 前缀相同的key不会遍历到
+	_, err = rds.SAdd([]byte("tags2"), []byte("other"))
+	assert.Nil(t, err)
+
+	members, err := rds.SMembers([]byte("tags"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("db"), []byte("go"), []byte("kv")}, members)
+
+	// 提前停止遍历
+	var scanned int
+	err = rds.SScan([]byte("tags"), func(member []byte) bool {
+		scanned++
+		return false
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, scanned)
+
+	deleted, err := rds.SRem([]byte("tags"), []byte("go"), []byte("go"), []byte("not-exist"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	members, err = rds.SMembers([]byte("tags"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("db"), []byte("kv")}, members)
+
+	// 成员全部删除之后key也被删除
+	deleted, err = rds.SRem([]byte("tags"), []byte("db"), []byte("kv"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, deleted)
+	ok, err := rds.Exists([]byte("tags"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
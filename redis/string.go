@@ -0,0 +1,51 @@
+package redis
+
+import (
+	kv_go "kv-go"
+	"time"
+)
+
+// ============= String =============
+
+// 写入字符串，ttl <= 0 代表永不过期，会覆盖key原来的值和类型
+func (rds *RedisDataStructure) Set(key []byte, ttl time.Duration, value []byte) error {
+	if value == nil {
+		return nil
+	}
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+	return rds.db.PutWithTTL(metaKey(key), encodeString(ttlToExpire(ttl), value), ttl)
+}
+
+func (rds *RedisDataStructure) Get(key []byte) ([]byte, error) {
+	buf, err := rds.db.Get(metaKey(key))
+	if err != nil {
+		return nil, err
+	}
+	dataType, _, value := decodeValueHeader(buf)
+	if dataType != String {
+		return nil, ErrWrongTypeOperation
+	}
+	return value, nil
+}
+
+// 批量写入多个字符串，pairs为key value交替的数组，所有key一起写入成功或失败
+func (rds *RedisDataStructure) MSet(pairs ...[]byte) error {
+	if len(pairs)%2 != 0 {
+		return ErrWrongNumberOfArgs
+	}
+
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	for i := 0; i < len(pairs); i += 2 {
+		if err := wb.Put(metaKey(pairs[i]), encodeString(0, pairs[i+1])); err != nil {
+			return err
+		}
+	}
+	return wb.Commit()
+}
+
+func encodeString(expire int64, value []byte) []byte {
+	return append(encodeValueHeader(String, expire), value...)
+}
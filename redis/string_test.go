@@ -0,0 +1,64 @@
+package redis
+
+import (
+	kv_go "kv-go"
+	"kv-go/utils"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestRds(t *testing.T) *RedisDataStructure {
+	opts := kv_go.DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-redis")
+	opts.DirPath = dir
+	rds, err := NewRedisDataStructure(opts)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = rds.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return rds
+}
+
+func TestRedisDataStructure_Get(t *testing.T) {
+	rds := openTestRds(t)
+
+	err := rds.Set(utils.GetTestKey(1), 0, utils.RandomValue(100))
+	assert.Nil(t, err)
+	err = rds.Set(utils.GetTestKey(2), time.Second*5, utils.RandomValue(100))
+	assert.Nil(t, err)
+
+	val1, err := rds.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.NotNil(t, val1)
+
+	val2, err := rds.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.NotNil(t, val2)
+
+	_, err = rds.Get(utils.GetTestKey(33))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+
+	// 过期的key
+	err = rds.Set(utils.GetTestKey(3), time.Millisecond*10, utils.RandomValue(100))
+	assert.Nil(t, err)
+	time.Sleep(time.Millisecond * 20)
+	_, err = rds.Get(utils.GetTestKey(3))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+}
+
+func TestRedisDataStructure_MSet(t *testing.T) {
+	rds := openTestRds(t)
+
+	err := rds.MSet([]byte("k1"), []byte("v1"), []byte("k2"), []byte("v2"))
+	assert.Nil(t, err)
+	val, err := rds.Get([]byte("k2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+
+	err = rds.MSet([]byte("k3"))
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+}
@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"encoding/binary"
+	"errors"
+	kv_go "kv-go"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWrongTypeOperation = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrWrongNumberOfArgs  = errors.New("wrong number of arguments")
+	ErrScoreIsNaN         = errors.New("resulting score is not a number (NaN)")
+)
+
+type redisDataType = byte
+
+const (
+	String redisDataType = iota + 1
+	Hash
+	ZSet
+	List
+	Set
+)
+
+// 在kv_go上实现redis的数据结构
+// 每个redis的key在db中有一个元数据key，value的第一个字节是数据类型，之后是过期时间
+// 字符串的数据直接放在元数据之后，其他数据结构的数据放在单独的内部key中
+// 元数据key和内部key用不同的前缀区分，遍历元数据key时不会遍历到内部key
+const (
+	metaKeyPrefix byte = 'm'
+	dataKeyPrefix byte = 'd'
+)
+
+type RedisDataStructure struct {
+	db *kv_go.DB
+	// 修改数据结构需要先读取元数据再写入，写操作之间需要互斥
+	mu *sync.Mutex
+}
+
+func NewRedisDataStructure(config kv_go.Config) (*RedisDataStructure, error) {
+	db, err := kv_go.Open(config)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisDataStructure{db: db, mu: new(sync.Mutex)}, nil
+}
+
+func (rds *RedisDataStructure) Close() error {
+	return rds.db.Close()
+}
+
+func metaKey(key []byte) []byte {
+	return append([]byte{metaKeyPrefix}, key...)
+}
+
+// 编码元数据的头部：类型 + 过期时间，expire为过期时刻的纳秒时间戳，0代表永不过期
+func encodeValueHeader(dataType redisDataType, expire int64) []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen64)
+	buf[0] = dataType
+	n := 1 + binary.PutVarint(buf[1:], expire)
+	return buf[:n]
+}
+
+// 解码元数据头部，返回类型、过期时间和头部之后的数据
+func decodeValueHeader(buf []byte) (redisDataType, int64, []byte) {
+	dataType := buf[0]
+	expire, n := binary.Varint(buf[1:])
+	return dataType, expire, buf[1+n:]
+}
+
+// 过期时间转换成写入db的ttl
+func expireToTTL(expire int64) time.Duration {
+	if expire == 0 {
+		return 0
+	}
+	ttl := time.Until(time.Unix(0, expire))
+	// 已经过期的key，给一个最小的ttl让它立刻过期
+	if ttl <= 0 {
+		ttl = time.Nanosecond
+	}
+	return ttl
+}
+
+func ttlToExpire(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
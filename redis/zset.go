@@ -0,0 +1,252 @@
+package redis
+
+import (
+	"encoding/binary"
+	kv_go "kv-go"
+	"math"
+)
+
+// ============= ZSet =============
+// 每个成员有两个内部key：
+// 成员key：前缀 + key + 版本号 + 'm' + member，value是score，用于通过成员查找score
+// score key：前缀 + key + 版本号 + 's' + score + member，value为空，用于按score顺序遍历
+// score编码成8个字节，编码后的字节序和score的大小顺序一致，和索引中key的排序方式（bytes.Compare）相同
+
+const (
+	zsetMemberKeyType byte = 'm'
+	zsetScoreKeyType  byte = 's'
+)
+
+// zset的成员
+type ZSetMember struct {
+	Member []byte
+	Score  float64
+}
+
+func zsetMemberKey(key []byte, version int64, member []byte) []byte {
+	buf := append(dataKeyPrefixOf(key, version), zsetMemberKeyType)
+	return append(buf, member...)
+}
+
+func zsetScoreKeyPrefix(key []byte, version int64) []byte {
+	return append(dataKeyPrefixOf(key, version), zsetScoreKeyType)
+}
+
+func zsetScoreKey(key []byte, version int64, score float64, member []byte) []byte {
+	buf := append(zsetScoreKeyPrefix(key, version), encodeScore(score)...)
+	return append(buf, member...)
+}
+
+// 正数把符号位置为1，负数所有位取反，编码后按字节比较的顺序和float64的大小顺序一致
+func encodeScore(score float64) []byte {
+	// -0和+0相等，统一成+0
+	if score == 0 {
+		score = 0
+	}
+	bits := math.Float64bits(score)
+	if bits&(1<<63) == 0 {
+		bits |= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, bits)
+	return buf
+}
+
+func decodeScore(buf []byte) float64 {
+	bits := binary.BigEndian.Uint64(buf)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+// 添加成员或者更新成员的score，返回是否是新的成员
+func (rds *RedisDataStructure) ZAdd(key []byte, score float64, member []byte) (bool, error) {
+	if math.IsNaN(score) {
+		return false, ErrScoreIsNaN
+	}
+
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return false, err
+	}
+
+	memberKey := zsetMemberKey(key, md.version, member)
+	oldScore, err := rds.db.Get(memberKey)
+	if err != nil && err != kv_go.ErrKeyNotFound {
+		return false, err
+	}
+	exist := err == nil
+	if exist && decodeScore(oldScore) == score {
+		return false, nil
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	if exist {
+		// 删除旧的score key
+		if err := wb.Delete(zsetScoreKey(key, md.version, decodeScore(oldScore), member)); err != nil {
+			return false, err
+		}
+	} else {
+		md.size++
+		if err := wb.PutWithTTL(metaKey(key), md.encode(), md.ttl()); err != nil {
+			return false, err
+		}
+	}
+	if err := wb.Put(memberKey, encodeScore(score)); err != nil {
+		return false, err
+	}
+	if err := wb.Put(zsetScoreKey(key, md.version, score, member), nil); err != nil {
+		return false, err
+	}
+	if err := wb.Commit(); err != nil {
+		return false, err
+	}
+	return !exist, nil
+}
+
+// 成员不存在时返回ErrKeyNotFound
+func (rds *RedisDataStructure) ZScore(key []byte, member []byte) (float64, error) {
+	md, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return 0, err
+	}
+	if md.size == 0 {
+		return 0, kv_go.ErrKeyNotFound
+	}
+
+	buf, err := rds.db.Get(zsetMemberKey(key, md.version, member))
+	if err != nil {
+		return 0, err
+	}
+	return decodeScore(buf), nil
+}
+
+// 删除多个成员，返回删除的成员数量，成员全部删除之后key也被删除
+func (rds *RedisDataStructure) ZRem(key []byte, members ...[]byte) (int, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	md, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return 0, err
+	}
+	if md.size == 0 {
+		return 0, nil
+	}
+
+	wb := rds.db.NewWriteBatch(kv_go.DefaultWriteBatchOptions)
+	deleted := make(map[string]struct{})
+	for _, member := range members {
+		if _, ok := deleted[string(member)]; ok {
+			continue
+		}
+		memberKey := zsetMemberKey(key, md.version, member)
+		buf, err := rds.db.Get(memberKey)
+		if err == kv_go.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		deleted[string(member)] = struct{}{}
+		if err := wb.Delete(memberKey); err != nil {
+			return 0, err
+		}
+		if err := wb.Delete(zsetScoreKey(key, md.version, decodeScore(buf), member)); err != nil {
+			return 0, err
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	md.size -= uint32(len(deleted))
+	if md.size == 0 {
+		err = wb.Delete(metaKey(key))
+	} else {
+		err = wb.PutWithTTL(metaKey(key), md.encode(), md.ttl())
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
+// 返回score在[min, max]之间的成员，按照score从小到大排序，score相同时按照成员的字节序排序
+func (rds *RedisDataStructure) ZRangeByScore(key []byte, min, max float64) ([]*ZSetMember, error) {
+	md, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return nil, err
+	}
+	if md.size == 0 || min > max {
+		return nil, nil
+	}
+
+	prefix := zsetScoreKeyPrefix(key, md.version)
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = prefix
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	var result []*ZSetMember
+	for iter.Seek(append(prefix, encodeScore(min)...)); iter.Valid(); iter.Next() {
+		buf := iter.Key()[len(prefix):]
+		score := decodeScore(buf[:8])
+		if score > max {
+			break
+		}
+		result = append(result, &ZSetMember{Member: buf[8:], Score: score})
+	}
+	return result, nil
+}
+
+// 返回成员按照score从小到大排序的位置，从0开始，成员不存在时返回ErrKeyNotFound
+func (rds *RedisDataStructure) ZRank(key []byte, member []byte) (int, error) {
+	md, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return 0, err
+	}
+	if md.size == 0 {
+		return 0, kv_go.ErrKeyNotFound
+	}
+
+	buf, err := rds.db.Get(zsetMemberKey(key, md.version, member))
+	if err != nil {
+		return 0, err
+	}
+	scoreKey := zsetScoreKey(key, md.version, decodeScore(buf), member)
+
+	// 数一下score key之前有多少个成员
+	iterConfig := kv_go.DefaultIteratorConfig
+	iterConfig.Prefix = zsetScoreKeyPrefix(key, md.version)
+	iter := rds.db.NewIterator(iterConfig)
+	defer iter.Close()
+
+	var rank int
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		if string(iter.Key()) == string(scoreKey) {
+			return rank, nil
+		}
+		rank++
+	}
+	return 0, kv_go.ErrKeyNotFound
+}
+
+func (rds *RedisDataStructure) ZCard(key []byte) (uint32, error) {
+	md, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return 0, err
+	}
+	return md.size, nil
+}
@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"bytes"
+	kv_go "kv-go"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeScore(t *testing.T) {
+	scores := []float64{math.Inf(-1), -1e300, -100.5, -1, -0.001, 0, 0.001, 1, 2, 100.5, 1e300, math.Inf(1)}
+	encoded := make([][]byte, len(scores))
+	for i, score := range scores {
+		encoded[i] = encodeScore(score)
+		assert.Equal(t, score, decodeScore(encoded[i]))
+	}
+	// 编码后的字节序和score的大小顺序一致
+	assert.True(t, sort.SliceIsSorted(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	}))
+	assert.Equal(t, encodeScore(0), encodeScore(math.Copysign(0, -1)))
+}
+
+func TestRedisDataStructure_ZAdd_ZScore(t *testing.T) {
+	rds := openTestRds(t)
+
+	ok, err := rds.ZAdd([]byte("board"), 100, []byte("alice"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.ZAdd([]byte("board"), 90, []byte("bob"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// 更新score
+	ok, err = rds.ZAdd([]byte("board"), 80, []byte("alice"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	score, err := rds.ZScore([]byte("board"), []byte("alice"))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(80), score)
+	_, err = rds.ZScore([]byte("board"), []byte("not-exist"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+
+	size, err := rds.ZCard([]byte("board"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), size)
+
+	// 旧的score不会再被遍历到
+	members, err := rds.ZRangeByScore([]byte("board"), math.Inf(-1), math.Inf(1))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(members))
+	assert.Equal(t, []byte("alice"), members[0].Member)
+
+	_, err = rds.ZAdd([]byte("board"), math.NaN(), []byte("c"))
+	assert.Equal(t, ErrScoreIsNaN, err)
+}
+
+func TestRedisDataStructure_ZRangeByScore_ZRank(t *testing.T) {
+	rds := openTestRds(t)
+
+	scores := map[string]float64{"a": -10, "b": 0, "c": 2.5, "d": 2.5, "e": 100}
+	for member, score := range scores {
+		_, err := rds.ZAdd([]byte("board"), score, []byte(member))
+		assert.Nil(t, err)
+	}
+
+	members, err := rds.ZRangeByScore([]byte("board"), 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(members))
+	assert.Equal(t, &ZSetMember{Member: []byte("b"), Score: 0}, members[0])
+	assert.Equal(t, &ZSetMember{Member: []byte("c"), Score: 2.5}, members[1])
+	assert.Equal(t, &ZSetMember{Member: []byte("d"), Score: 2.5}, members[2])
+
+	members, err = rds.ZRangeByScore([]byte("board"), -100, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(members))
+	assert.Equal(t, []byte("a"), members[0].Member)
+
+	members, err = rds.ZRangeByScore([]byte("board"), 200, 300)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(members))
+
+	for i, member := range []string{"a", "b", "c", "d", "e"} {
+		rank, err := rds.ZRank([]byte("board"), []byte(member))
+		assert.Nil(t, err)
+		assert.Equal(t, i, rank)
+	}
+	_, err = rds.ZRank([]byte("board"), []byte("not-exist"))
+	assert.Equal(t, kv_go.ErrKeyNotFound, err)
+}
+
+func TestRedisDataStructure_ZRem(t *testing.T) {
+	rds := openTestRds(t)
+
+	_, err := rds.ZAdd([]byte("board"), 1, []byte("a"))
+	assert.Nil(t, err)
+	_, err = rds.ZAdd([]byte("board"), 2, []byte("b"))
+	assert.Nil(t, err)
+
+	deleted, err := rds.ZRem([]byte("board"), []byte("a"), []byte("a"), []byte("not-exist"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+
+	members, err := rds.ZRangeByScore([]byte("board"), math.Inf(-1), math.Inf(1))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(members))
+	assert.Equal(t, []byte("b"), members[0].Member)
+
+	// 成员全部删除之后key也被删除
+	deleted, err = rds.ZRem([]byte("board"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	ok, err := rds.Exists([]byte("board"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
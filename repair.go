@@ -0,0 +1,306 @@
+package kv_go
+
+import (
+	"io"
+	"kv-go/data"
+	"kv-go/fio"
+	"kv-go/index"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gofrs/flock"
+)
+
+// 修复损坏的数据目录
+// 按顺序读取所有数据文件，遇到损坏的数据时逐个字节向后查找下一条crc正确的数据
+// 每个key最新的有效数据写入一个新的目录，新的目录替换原来的目录，原来的目录保留为dirPath-corrupted
+// 损坏的数据如果是一次删除，修复之后这个key旧的数据会重新出现
+
+const (
+	repairDirName    = "-repair"
+	corruptedDirName = "-corrupted"
+)
+
+type RepairReport struct {
+	Records        int    // 读取到的完整数据条数
+	RecoveredKeys  int    // 写入新目录的key数量
+	LostKeys       int    // 数据损坏并且没有其他版本可以恢复的key数量
+	StaleKeys      int    // 最新的数据损坏了，恢复成旧版本的key数量
+	CorruptRegions int    // 跳过的损坏区域数量
+	SkippedBytes   int64  // 跳过的字节数
+	CorruptedDir   string // 原来的数据目录移动到了这里
+}
+
+type repairer struct {
+	report      *RepairReport
+	dataFiles   map[uint32]*data.DataFile
+	latest      map[string]*data.LogRecordPos // 每个key最新的数据
+	deleted     map[string]*data.LogRecordPos // 最后一次操作是删除的key
+	damaged     map[string]*data.LogRecordPos // 能读出key的损坏数据
+	pendingTxns map[uint64][]*data.TransactionRecord
+}
+
+// 修复数据目录，目录不能被其他进程打开
+// 损坏的数据中key只有在header完整时才能读出来，LostKeys不包括key也损坏了的数据
+func Repair(dirPath string) (*RepairReport, error) {
+	dirPath = filepath.Clean(dirPath)
+	if _, err := os.Stat(dirPath); err != nil {
+		return nil, err
+	}
+	fileLock := flock.New(filepath.Join(dirPath, fileLockName))
+	hold, err := fileLock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !hold {
+		return nil, ErrDatabaseIsUsing
+	}
+	defer func() {
+		_ = fileLock.Unlock()
+	}()
+	corruptedDir := dirPath + corruptedDirName
+	if _, err := os.Stat(corruptedDir); err == nil {
+		return nil, ErrRepairDirExists
+	}
+
+	r := &repairer{
+		report:      &RepairReport{},
+		dataFiles:   make(map[uint32]*data.DataFile),
+		latest:      make(map[string]*data.LogRecordPos),
+		deleted:     make(map[string]*data.LogRecordPos),
+		damaged:     make(map[string]*data.LogRecordPos),
+		pendingTxns: make(map[uint64][]*data.TransactionRecord),
+	}
+	defer r.close()
+
+	fileIds, err := getDataFileIds(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	// merge过的文件id比没有merge的小，按id的顺序读取，后面的数据就是更新的
+	for _, fid := range fileIds {
+		dataFile, err := data.OpenDataFile(dirPath, uint32(fid), fio.MemoryMap)
+		if err != nil {
+			return nil, err
+		}
+		r.dataFiles[uint32(fid)] = dataFile
+		if err := r.scanDataFile(dataFile); err != nil {
+			return nil, err
+		}
+	}
+	r.checkHintFile(dirPath)
+	r.countLostKeys()
+
+	repairDir := dirPath + repairDirName
+	if err := os.RemoveAll(repairDir); err != nil {
+		return nil, err
+	}
+	// 原来使用b+树索引时，新的目录也需要b+树索引文件
+	indexType := Btree
+	if _, err := os.Stat(filepath.Join(dirPath, index.BPlusTreeIndexFileName)); err == nil {
+		indexType = BPlusTree
+	}
+	config := DefaultConfig
+	config.DirPath = repairDir
+	config.IndexType = indexType
+	repairDB, err := Open(config)
+	if err != nil {
+		return nil, err
+	}
+	err = r.writeRecords(repairDB)
+	// 保留原来的序列号上限，修复之后分配的序列号不会和之前的重复
+	if err == nil {
+		err = copySeqNoLease(dirPath, repairDir)
+	}
+	if closeErr := repairDB.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.close()
+
+	// 原来的目录和没有完成的merge目录一起保留
+	if err := os.Rename(dirPath, corruptedDir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(repairDir, dirPath); err != nil {
+		_ = os.Rename(corruptedDir, dirPath)
+		return nil, err
+	}
+	mergePath := dirPath + mergeDirName
+	if _, err := os.Stat(mergePath); err == nil {
+		if err := os.Rename(mergePath, corruptedDir+mergeDirName); err != nil {
+			return nil, err
+		}
+	}
+	r.report.CorruptedDir = corruptedDir
+	return r.report, nil
+}
+
+func (r *repairer) close() {
+	for fid, dataFile := range r.dataFiles {
+		_ = dataFile.Close()
+		delete(r.dataFiles, fid)
+	}
+}
+
+func (r *repairer) scanDataFile(dataFile *data.DataFile) error {
+	fileSize, err := dataFile.IOManager.Size()
+	if err != nil {
+		return err
+	}
+
+	var offset int64 = 0
+	for offset < fileSize {
+		logRecord, size, err := dataFile.Read(offset)
+		if err == nil && logRecord != nil {
+			r.report.Records++
+			r.addRecord(logRecord, &data.LogRecordPos{
+				Fid:    dataFile.FileId,
+				Offset: offset,
+				Size:   uint32(size),
+				Expire: logRecord.Expire,
+			})
+			offset += size
+			continue
+		}
+		if err != nil && err != io.EOF && err != data.ErrInvalidCRC {
+			return err
+		}
+
+		next := r.resync(dataFile, offset, fileSize)
+		r.report.CorruptRegions++
+		r.report.SkippedBytes += next - offset
+		offset = next
+	}
+	return nil
+}
+
+// 和加载索引时一样，事务的数据读取到事务完成的标记之后才生效
+func (r *repairer) addRecord(logRecord *data.LogRecord, pos *data.LogRecordPos) {
+	key, seqNo := parseSeqLogRecordKey(logRecord.Key)
+	if seqNo == nonTxnSeqNo {
+		r.apply(key, logRecord.Type, pos)
+		return
+	}
+	if logRecord.Type == data.LogRecordTxnFinished {
+		for _, txnRecord := range r.pendingTxns[seqNo] {
+			r.apply(txnRecord.Record.Key, txnRecord.Record.Type, txnRecord.Pos)
+		}
+		delete(r.pendingTxns, seqNo)
+		return
+	}
+	// value写入新目录时再读取，这里不保存
+	r.pendingTxns[seqNo] = append(r.pendingTxns[seqNo], &data.TransactionRecord{
+		Record: &data.LogRecord{Key: key, Type: logRecord.Type},
+		Pos:    pos,
+	})
+}
+
+func (r *repairer) apply(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) {
+	if typ == data.LogRecordDeleted {
+		delete(r.latest, string(key))
+		r.deleted[string(key)] = pos
+		return
+	}
+	r.latest[string(key)] = pos
+	delete(r.deleted, string(key))
+}
+
+// 返回offset之后下一条有效数据的位置，没有找到时返回文件大小
+func (r *repairer) resync(dataFile *data.DataFile, offset, fileSize int64) int64 {
+	// 只有内容损坏时header记录的长度是正确的，长度之后就是下一条有效数据
+	if logRecord, size, err := dataFile.ReadUnchecked(offset); err == nil && logRecord != nil && size > 0 {
+		end := offset + size
+		if end == fileSize || validRecordAt(dataFile, end) {
+			key, _ := parseSeqLogRecordKey(logRecord.Key)
+			if logRecord.Type == data.LogRecordNormal {
+				r.damaged[string(key)] = &data.LogRecordPos{Fid: dataFile.FileId, Offset: offset}
+			}
+			return end
+		}
+	}
+
+	for next := offset + 1; next < fileSize; next++ {
+		if validRecordAt(dataFile, next) {
+			return next
+		}
+	}
+	return fileSize
+}
+
+// hint文件中指向无法读取的数据的key也是损坏的
+func (r *repairer) checkHintFile(dirPath string) {
+	filePath := filepath.Join(dirPath, data.HintFileName)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return
+	}
+	hintFile, err := openReadOnlyFile(filePath)
+	if err != nil {
+		return
+	}
+	defer hintFile.Close()
+
+	var offset int64 = 0
+	for {
+		logRecord, size, err := hintFile.Read(offset)
+		if err != nil || logRecord == nil {
+			return
+		}
+		pos := data.DecodeLogRecordPos(logRecord.Value)
+		if dataFile, ok := r.dataFiles[pos.Fid]; !ok || !validRecordAt(dataFile, pos.Offset) {
+			r.damaged[string(logRecord.Key)] = pos
+		}
+		offset += size
+	}
+}
+
+// 位置a是否在位置b之前写入
+func writtenBefore(a, b *data.LogRecordPos) bool {
+	return a.Fid < b.Fid || (a.Fid == b.Fid && a.Offset < b.Offset)
+}
+
+func (r *repairer) countLostKeys() {
+	for key, damagedPos := range r.damaged {
+		if pos, ok := r.latest[key]; ok {
+			if writtenBefore(pos, damagedPos) {
+				r.report.StaleKeys++
+			}
+			continue
+		}
+		// 损坏的数据在删除之后写入，这次写入丢失了
+		if pos, ok := r.deleted[key]; !ok || writtenBefore(pos, damagedPos) {
+			r.report.LostKeys++
+		}
+	}
+}
+
+// 把每个key最新的数据按key的顺序写入新的目录
+func (r *repairer) writeRecords(repairDB *DB) error {
+	keys := make([]string, 0, len(r.latest))
+	for key := range r.latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		pos := r.latest[key]
+		logRecord, err := r.dataFiles[pos.Fid].ReadAt(pos)
+		if err != nil {
+			return err
+		}
+		if logRecord.IsExpired() {
+			continue
+		}
+		logRecord.Key = createLogRecordKeyWithSeq([]byte(key), nonTxnSeqNo)
+		newPos, err := repairDB.appendLogRecord(logRecord)
+		if err != nil {
+			return err
+		}
+		repairDB.index.Put([]byte(key), newPos)
+		r.report.RecoveredKeys++
+	}
+	return repairDB.Sync()
+}
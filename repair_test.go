@@ -0,0 +1,95 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 覆盖数据文件中的一段字节
+func corruptDataFile(t *testing.T, dirPath string, pos *data.LogRecordPos, offset int64, buf []byte) {
+	f, err := os.OpenFile(data.GetDatafilePath(dirPath, pos.Fid), os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteAt(buf, pos.Offset+offset)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+}
+
+func TestRepair(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-repair")
+	opts.DirPath = dir
+	opts.DataFileSize = 8 * 1024
+	defer os.RemoveAll(dir + corruptedDirName)
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 200; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	// key 60的最新版本会被损坏，修复之后是旧版本
+	assert.Nil(t, db.Put(utils.GetTestKey(60), []byte("new-value")))
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	for i := 200; i < 210; i++ {
+		assert.Nil(t, wb.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	assert.Nil(t, wb.Commit())
+	for i := 210; i < 400; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i)))
+	}
+	lostPos := db.index.Get(utils.GetTestKey(50))
+	stalePos := db.index.Get(utils.GetTestKey(60))
+	regionPos := db.index.Get(utils.GetTestKey(100))
+	assert.Nil(t, db.Close())
+
+	// key 50和key 60只损坏了value，从key 100开始有一段header也损坏了
+	corruptDataFile(t, dir, lostPos, int64(lostPos.Size)-1, []byte{0xff})
+	corruptDataFile(t, dir, stalePos, int64(stalePos.Size)-1, []byte{0xff})
+	garbage := make([]byte, 50)
+	for i := range garbage {
+		garbage[i] = 0xff
+	}
+	corruptDataFile(t, dir, regionPos, 0, garbage)
+	_, err = Open(opts)
+	assert.Equal(t, data.ErrInvalidCRC, err)
+
+	report, err := Repair(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.CorruptRegions)
+	assert.Equal(t, 1, report.LostKeys)
+	assert.Equal(t, 1, report.StaleKeys)
+	assert.Equal(t, dir+corruptedDirName, report.CorruptedDir)
+	_, err = os.Stat(report.CorruptedDir)
+	assert.Nil(t, err)
+
+	db, err = Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Equal(t, report.RecoveredKeys, len(db.ListKeys()))
+	_, err = db.Get(utils.GetTestKey(5))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db.Get(utils.GetTestKey(50))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := db.Get(utils.GetTestKey(60))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(60), val)
+	for i := 200; i < 400; i++ {
+		val, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+
+	// 数据库打开时不能修复，已经有保留损坏数据的目录时也不能再修复
+	_, err = Repair(dir)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+	assert.Nil(t, db.Close())
+	_, err = Repair(dir)
+	assert.Equal(t, ErrRepairDirExists, err)
+}
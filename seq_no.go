@@ -0,0 +1,112 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/fio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+)
+
+// 序列号
+// WriteBatch和Txn的序列号写在数据文件中key的前面，普通写入在有事务或者watcher时才分配序列号，不写入数据文件
+// 分配之前先持久化一个序列号上限，重启之后从上限开始继续分配，异常退出之后也不会分配重复的序列号
+// 上限和db.seqNo分开保存，打开时db.seqNo还是从数据文件或者seq-no文件中恢复的值，第一次分配时才跳过上限
+// 上限每次增加seqNoLeaseStep，不需要每次写入都持久化，重启前后的序列号不连续
+
+const seqNoLeaseStep uint64 = 10000
+
+// 分配一个新的序列号，调用时需要持有db.mu
+func (db *DB) nextSeqNo() (uint64, error) {
+	seqNo := db.seqNo + 1
+	// 上一次运行可能已经分配过这些序列号
+	if seqNo <= db.seqNoFloor {
+		seqNo = db.seqNoFloor + 1
+	}
+	if seqNo > db.seqNoLease {
+		lease := seqNo + seqNoLeaseStep
+		if err := writeSeqNoLease(db.config.DirPath, lease); err != nil {
+			return 0, err
+		}
+		db.seqNoLease = lease
+	}
+	atomic.StoreUint64(&db.seqNo, seqNo)
+	return seqNo, nil
+}
+
+// 读取上一次运行的序列号上限，之后分配的序列号都比它大
+func (db *DB) loadSeqNoLease() error {
+	lease, err := readSeqNoLease(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+	db.seqNoFloor = lease
+	db.seqNoLease = lease
+	return nil
+}
+
+// 没有上限文件时返回0
+func readSeqNoLease(dirPath string) (uint64, error) {
+	fileName := filepath.Join(dirPath, data.SeqNoLeaseFileName)
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	leaseFile, err := data.OpenSeqNoLeaseFile(dirPath)
+	if err != nil {
+		return 0, err
+	}
+	defer leaseFile.Close()
+	record, _, err := leaseFile.Read(0)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(record.Value), 10, 64)
+}
+
+// 先写入临时文件再重命名，异常退出时不会留下不完整的上限文件
+func writeSeqNoLease(dirPath string, lease uint64) error {
+	record := &data.LogRecord{
+		Key:   []byte(data.SeqNoLeaseFileName),
+		Value: []byte(strconv.FormatUint(lease, 10)),
+	}
+	encRecord, _ := data.EncodeLogRecord(record)
+
+	tmpPath := filepath.Join(dirPath, data.SeqNoLeaseFileName+".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fio.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encRecord); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dirPath, data.SeqNoLeaseFileName)); err != nil {
+		return err
+	}
+
+	// 重命名也需要持久化
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// 把srcDir的序列号上限写入dstDir
+func copySeqNoLease(srcDir, dstDir string) error {
+	lease, err := readSeqNoLease(srcDir)
+	if err != nil || lease == 0 {
+		return err
+	}
+	return writeSeqNoLease(dstDir, lease)
+}
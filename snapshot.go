@@ -0,0 +1,95 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/index"
+	"sync"
+)
+
+// 快照，保存创建时的索引和数据文件，之后的写入和merge对快照不可见
+// merge的结果在下次Open时才替换数据文件，快照使用的旧文件在db关闭之前一直有效
+// 用完之后需要调用Release，释放索引的副本
+type Snapshot struct {
+	db         *DB
+	mu         *sync.Mutex
+	index      index.Indexer
+	activeFile *data.DataFile
+	olderFiles map[uint32]*data.DataFile
+	released   bool
+}
+
+func (db *DB) Snapshot() *Snapshot {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	olderFiles := make(map[uint32]*data.DataFile, len(db.olderFiles))
+	for fid, dataFile := range db.olderFiles {
+		olderFiles[fid] = dataFile
+	}
+
+	return &Snapshot{
+		db:         db,
+		mu:         new(sync.Mutex),
+		index:      db.index.Clone(),
+		activeFile: db.activeFile,
+		olderFiles: olderFiles,
+	}
+}
+
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released {
+		return nil, ErrSnapshotReleased
+	}
+
+	logRecordPos := s.index.Get(key)
+	//key不存在或者已经过期
+	if logRecordPos == nil || logRecordPos.IsExpired() {
+		return nil, ErrKeyNotFound
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return s.getValueByPosition(logRecordPos)
+}
+
+// 迭代器只能在快照释放之前使用
+func (s *Snapshot) NewIterator(config IteratorConfig) *Iterator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	iter := &Iterator{
+		db:        s.db,
+		snapshot:  s,
+		indexIter: index.NewPrefixIterator(s.index, config.Prefix, config.Reverse),
+		config:    config,
+	}
+	iter.skipToNext()
+	return iter
+}
+
+// 释放快照，关闭索引的副本
+func (s *Snapshot) Release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released {
+		return nil
+	}
+	s.released = true
+	return s.index.Close()
+}
+
+// 从快照创建时的数据文件中读取
+func (s *Snapshot) getValueByPosition(logRecordPos *data.LogRecordPos) ([]byte, error) {
+	var dataFile *data.DataFile
+	if s.activeFile != nil && s.activeFile.FileId == logRecordPos.Fid {
+		dataFile = s.activeFile
+	} else {
+		dataFile = s.olderFiles[logRecordPos.Fid]
+	}
+	return readValue(dataFile, logRecordPos)
+}
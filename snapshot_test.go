@@ -0,0 +1,122 @@
+package kv_go
+
+import (
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_Snapshot(t *testing.T) {
+	for _, indexType := range []IndexType{Btree, ART, BPlusTree} {
+		opts := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask-go-snapshot")
+		opts.DirPath = dir
+		opts.IndexType = indexType
+		db, err := Open(opts)
+		assert.Nil(t, err)
+		assert.NotNil(t, db)
+
+		for i := 0; i < 10; i++ {
+			err := db.Put(utils.GetTestKey(i), []byte("old value"))
+			assert.Nil(t, err)
+		}
+
+		snap := db.Snapshot()
+
+		// 创建快照之后的修改对快照不可见
+		err = db.Put(utils.GetTestKey(1), []byte("new value"))
+		assert.Nil(t, err)
+		err = db.Delete(utils.GetTestKey(2))
+		assert.Nil(t, err)
+		err = db.Put(utils.GetTestKey(10), []byte("new value"))
+		assert.Nil(t, err)
+
+		val, err := snap.Get(utils.GetTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("old value"), val)
+		val, err = snap.Get(utils.GetTestKey(2))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("old value"), val)
+		_, err = snap.Get(utils.GetTestKey(10))
+		assert.Equal(t, ErrKeyNotFound, err)
+
+		var count int
+		iter := snap.NewIterator(DefaultIteratorConfig)
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			val, err := iter.Value()
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("old value"), val)
+			count++
+		}
+		iter.Close()
+		assert.Equal(t, 10, count)
+
+		// 数据库中是最新的数据
+		val, err = db.Get(utils.GetTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("new value"), val)
+
+		err = snap.Release()
+		assert.Nil(t, err)
+		_, err = snap.Get(utils.GetTestKey(1))
+		assert.Equal(t, ErrSnapshotReleased, err)
+		destroyDB(db)
+	}
+}
+
+// 有快照时merge，等快照释放之后再替换数据文件
+func TestDB_SnapshotMerge(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-snapshot-merge")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 500; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	snap := db.Snapshot()
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	// merge文件还没有被加载
+	_, err = os.Stat(db.getMergePath())
+	assert.Nil(t, err)
+	for i := 500; i < 1000; i++ {
+		val, err := snap.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i), val)
+	}
+
+	err = snap.Release()
+	assert.Nil(t, err)
+	// merge文件在下次Open时才加载
+	_, err = os.Stat(db.getMergePath())
+	assert.Nil(t, err)
+	val, err := db.Get(utils.GetTestKey(999))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(999), val)
+
+	// 重启之后从merge之后的文件中读取
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 500, len(db2.ListKeys()))
+	_, err = os.Stat(db.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+	val, err = db2.Get(utils.GetTestKey(999))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(999), val)
+	_ = db2.Close()
+}
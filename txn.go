@@ -0,0 +1,305 @@
+package kv_go
+
+import (
+	"bytes"
+	"kv-go/data"
+	"kv-go/index"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// 乐观事务
+// 开始时记录当前的seqNo，事务中读取过的key都记录下来
+// 事务运行期间，db每次修改key都会记录修改时的seqNo
+// 提交时如果读取过的key的修改seqNo大于事务开始时的seqNo，说明被其他写入修改过，提交失败
+// 提交和WriteBatch一样，用新的seqNo写入所有数据，最后写入LogRecordTxnFinished
+// 组提交时写入在持久化之后才更新索引，开始时或者提交时还没有更新到索引中的key，读取到的都可能是旧的数据，也算冲突
+
+type Txn struct {
+	db            *DB
+	mu            *sync.Mutex
+	startSeqNo    uint64
+	reads         map[string]struct{}
+	pendingWrites map[string]*data.LogRecord
+	unapplied     map[string]struct{} // 开始时已经写入数据文件但还没有更新到索引中的key
+	finished      bool
+}
+
+// 开始一个事务，用完之后必须调用Commit或者Discard
+// 没有结束的事务会一直占用db：修改记录keyVersions不断增长，普通写入也要分配并写入序列号
+func (db *DB) Begin() *Txn {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.activeTxnNum += 1
+	return &Txn{
+		db:            db,
+		mu:            new(sync.Mutex),
+		startSeqNo:    atomic.LoadUint64(&db.seqNo),
+		reads:         make(map[string]struct{}),
+		pendingWrites: make(map[string]*data.LogRecord),
+		unapplied:     db.unappliedKeys(),
+	}
+}
+
+// 读取数据，优先读取事务中还没有提交的数据
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return nil, ErrTxnFinished
+	}
+
+	if record, ok := txn.pendingWrites[string(key)]; ok {
+		if record.Type == data.LogRecordDeleted || record.IsExpired() {
+			return nil, ErrKeyNotFound
+		}
+		return record.Value, nil
+	}
+
+	// 不存在的key也要记录，提交前被其他写入创建了也算冲突
+	txn.reads[string(key)] = struct{}{}
+	return txn.db.Get(key)
+}
+
+func (txn *Txn) Put(key []byte, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnFinished
+	}
+
+	txn.pendingWrites[string(key)] = &data.LogRecord{
+		Key:   key,
+		Value: value,
+		Type:  data.LogRecordNormal,
+	}
+	return nil
+}
+
+func (txn *Txn) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnFinished
+	}
+
+	// 数据不存在，只需要去掉事务中的写入
+	if pos := txn.db.index.Get(key); pos == nil {
+		delete(txn.pendingWrites, string(key))
+		return nil
+	}
+
+	txn.pendingWrites[string(key)] = &data.LogRecord{Key: key, Type: data.LogRecordDeleted}
+	return nil
+}
+
+// 提交事务，读取过的key在事务开始后被修改过就返回ErrTxnConflict
+// 没有写入的只读事务也会检测冲突，返回nil代表读取到的数据在提交时仍然是一致的
+// 不管成功还是失败，提交之后事务都结束了
+func (txn *Txn) Commit() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnFinished
+	}
+
+	db := txn.db
+	db.mu.Lock()
+	ticket, err := txn.commit()
+	db.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// 组提交时释放锁之后再持久化
+	return db.waitForSync(ticket)
+}
+
+// 检测冲突并写入数据，返回组提交的写入编号，调用时需要持有db.mu
+func (txn *Txn) commit() (uint64, error) {
+	db := txn.db
+	defer txn.finish()
+
+	// 检测冲突
+	unapplied := db.unappliedKeys()
+	for key := range txn.reads {
+		if seqNo, ok := db.keyVersions[key]; ok && seqNo > txn.startSeqNo {
+			return 0, ErrTxnConflict
+		}
+		if _, ok := txn.unapplied[key]; ok {
+			return 0, ErrTxnConflict
+		}
+		if _, ok := unapplied[key]; ok {
+			return 0, ErrTxnConflict
+		}
+	}
+
+	if len(txn.pendingWrites) == 0 {
+		return 0, nil
+	}
+
+	if db.config.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	return db.commitRecords(txn.pendingWrites, db.config.SyncWrites)
+}
+
+// 放弃事务中的所有写入
+func (txn *Txn) Discard() {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return
+	}
+
+	txn.db.mu.Lock()
+	defer txn.db.mu.Unlock()
+	txn.finish()
+}
+
+// 结束事务，调用时需要持有db.mu
+func (txn *Txn) finish() {
+	txn.finished = true
+	txn.pendingWrites = nil
+
+	txn.db.activeTxnNum -= 1
+	// 没有运行中的事务了，之前的修改记录都不再需要
+	if txn.db.activeTxnNum == 0 {
+		txn.db.keyVersions = make(map[string]uint64)
+	}
+}
+
+// 有事务在运行时记录key被修改时的序列号
+// 调用时需要持有db.mu
+func (db *DB) markKeyModified(key []byte, seqNo uint64) {
+	if db.activeTxnNum == 0 {
+		return
+	}
+	db.keyVersions[string(key)] = seqNo
+}
+
+// 普通写入不在磁盘上记录序列号，只有在有事务或者watcher时才分配一个新的序列号
+// 调用时需要持有db.mu
+func (db *DB) nextNonTxnSeqNo() (uint64, error) {
+	if db.activeTxnNum == 0 && len(db.watchers) == 0 {
+		return nonTxnSeqNo, nil
+	}
+	return db.nextSeqNo()
+}
+
+// 事务中的迭代器，包含事务中还没有提交的数据，通过迭代器读取value也会被记录为读取过
+func (txn *Txn) NewIterator(config IteratorConfig) *Iterator {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	var items []*txnIteratorItem
+	indexIter := index.NewPrefixIterator(txn.db.index, config.Prefix, config.Reverse)
+	for indexIter.Rewind(); indexIter.Valid(); indexIter.Next() {
+		key := indexIter.Key()
+		// 被事务修改过的key用事务中的数据
+		if _, ok := txn.pendingWrites[string(key)]; ok {
+			continue
+		}
+		items = append(items, &txnIteratorItem{key: key, pos: indexIter.Value()})
+	}
+	indexIter.Close()
+
+	for _, record := range txn.pendingWrites {
+		if record.Type == data.LogRecordDeleted {
+			continue
+		}
+		items = append(items, &txnIteratorItem{key: record.Key, pos: &data.LogRecordPos{Expire: record.Expire}})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if config.Reverse {
+			return bytes.Compare(items[i].key, items[j].key) > 0
+		}
+		return bytes.Compare(items[i].key, items[j].key) < 0
+	})
+
+	iter := &Iterator{
+		db:        txn.db,
+		txn:       txn,
+		indexIter: &txnIterator{reverse: config.Reverse, items: items},
+		config:    config,
+	}
+	iter.skipToNext()
+	return iter
+}
+
+// 迭代器读取value
+func (txn *Txn) getValue(key []byte, logRecordPos *data.LogRecordPos) ([]byte, error) {
+	txn.mu.Lock()
+	if record, ok := txn.pendingWrites[string(key)]; ok {
+		txn.mu.Unlock()
+		return record.Value, nil
+	}
+	txn.reads[string(key)] = struct{}{}
+	txn.mu.Unlock()
+
+	txn.db.mu.RLock()
+	defer txn.db.mu.RUnlock()
+	return txn.db.getValueByPosition(logRecordPos)
+}
+
+type txnIteratorItem struct {
+	key []byte
+	pos *data.LogRecordPos
+}
+
+// 合并了事务中数据的索引迭代器，和btreeIterator一样遍历一个排好序的数组
+type txnIterator struct {
+	currIndex int
+	reverse   bool
+	items     []*txnIteratorItem
+}
+
+func (iter *txnIterator) Rewind() {
+	iter.currIndex = 0
+}
+
+func (iter *txnIterator) Seek(key []byte) {
+	if iter.reverse {
+		iter.currIndex = sort.Search(len(iter.items), func(i int) bool {
+			return bytes.Compare(iter.items[i].key, key) <= 0
+		})
+	} else {
+		iter.currIndex = sort.Search(len(iter.items), func(i int) bool {
+			return bytes.Compare(iter.items[i].key, key) >= 0
+		})
+	}
+}
+
+func (iter *txnIterator) Next() {
+	iter.currIndex += 1
+}
+
+func (iter *txnIterator) Valid() bool {
+	return iter.currIndex < len(iter.items)
+}
+
+func (iter *txnIterator) Key() []byte {
+	return iter.items[iter.currIndex].key
+}
+
+func (iter *txnIterator) Value() *data.LogRecordPos {
+	return iter.items[iter.currIndex].pos
+}
+
+func (iter *txnIterator) Close() {
+	iter.items = nil
+}
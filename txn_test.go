@@ -0,0 +1,188 @@
+package kv_go
+
+import (
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_Txn(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	err = db.Put(utils.GetTestKey(1), []byte("old value"))
+	assert.Nil(t, err)
+	err = db.Put(utils.GetTestKey(2), []byte("old value"))
+	assert.Nil(t, err)
+
+	txn := db.Begin()
+	// 事务中可以读到自己的写入，提交前对db不可见
+	err = txn.Put(utils.GetTestKey(3), []byte("txn value"))
+	assert.Nil(t, err)
+	err = txn.Delete(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	val, err := txn.Get(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("txn value"), val)
+	_, err = txn.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db.Get(utils.GetTestKey(3))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 迭代器合并事务中的数据
+	var keys [][]byte
+	iter := txn.NewIterator(DefaultIteratorConfig)
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	assert.Equal(t, [][]byte{utils.GetTestKey(1), utils.GetTestKey(3)}, keys)
+
+	err = txn.Commit()
+	assert.Nil(t, err)
+	val, err = db.Get(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("txn value"), val)
+	_, err = db.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Equal(t, ErrTxnFinished, txn.Commit())
+
+	// 重启后数据仍然存在
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	val, err = db2.Get(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("txn value"), val)
+	_ = db2.Close()
+}
+
+func TestDB_TxnConflict(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-conflict")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put([]byte("counter"), []byte("1"))
+	assert.Nil(t, err)
+
+	// 读取过的key被普通写入修改
+	txn1 := db.Begin()
+	_, err = txn1.Get([]byte("counter"))
+	assert.Nil(t, err)
+	err = db.Put([]byte("counter"), []byte("2"))
+	assert.Nil(t, err)
+	_ = txn1.Put([]byte("counter"), []byte("3"))
+	assert.Equal(t, ErrTxnConflict, txn1.Commit())
+	val, err := db.Get([]byte("counter"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("2"), val)
+
+	// 两个事务读写同一个key，后提交的失败
+	txn2 := db.Begin()
+	txn3 := db.Begin()
+	_, _ = txn2.Get([]byte("counter"))
+	_, _ = txn3.Get([]byte("counter"))
+	_ = txn2.Put([]byte("counter"), []byte("4"))
+	_ = txn3.Put([]byte("counter"), []byte("5"))
+	assert.Nil(t, txn2.Commit())
+	assert.Equal(t, ErrTxnConflict, txn3.Commit())
+
+	// 读取不存在的key之后被WriteBatch创建
+	txn4 := db.Begin()
+	_, err = txn4.Get([]byte("missing"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("missing"), []byte("created"))
+	assert.Nil(t, wb.Commit())
+	_ = txn4.Put([]byte("other"), []byte("value"))
+	assert.Equal(t, ErrTxnConflict, txn4.Commit())
+
+	// 只写入没有读取的key不冲突
+	txn5 := db.Begin()
+	_ = txn5.Put([]byte("counter"), []byte("6"))
+	err = db.Put([]byte("counter"), []byte("7"))
+	assert.Nil(t, err)
+	assert.Nil(t, txn5.Commit())
+	val, err = db.Get([]byte("counter"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("6"), val)
+
+	// 所有事务结束后不再记录修改
+	assert.Equal(t, 0, db.activeTxnNum)
+	assert.Equal(t, 0, len(db.keyVersions))
+}
+
+func TestDB_TxnReadOnlyConflict(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-readonly")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put([]byte("a"), []byte("1"))
+	assert.Nil(t, err)
+	err = db.Put([]byte("b"), []byte("1"))
+	assert.Nil(t, err)
+
+	// 读取到的数据没有被修改
+	txn1 := db.Begin()
+	_, err = txn1.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Nil(t, txn1.Commit())
+
+	// 只读事务读取过的key被修改，读取到的a和b不是同一时刻的数据
+	txn2 := db.Begin()
+	_, err = txn2.Get([]byte("a"))
+	assert.Nil(t, err)
+	err = db.Put([]byte("a"), []byte("2"))
+	assert.Nil(t, err)
+	_, err = txn2.Get([]byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, ErrTxnConflict, txn2.Commit())
+	assert.Equal(t, 0, db.activeTxnNum)
+}
+
+func TestDB_TxnNotFinished(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-leak")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 没有提交也没有放弃的事务，之后的普通写入都要记录修改并分配序列号
+	txn := db.Begin()
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, db.activeTxnNum)
+	assert.Equal(t, 100, len(db.keyVersions))
+	seqNo := db.seqNo
+	assert.True(t, seqNo >= 100)
+
+	// Discard之后清理修改记录，普通写入不再分配序列号
+	txn.Discard()
+	assert.Equal(t, 0, db.activeTxnNum)
+	assert.Equal(t, 0, len(db.keyVersions))
+	err = db.Put(utils.GetTestKey(100), utils.GetTestKey(100))
+	assert.Nil(t, err)
+	assert.Equal(t, seqNo, db.seqNo)
+	assert.Equal(t, 0, len(db.keyVersions))
+
+	// 重复Discard不会影响其他事务的计数
+	txn.Discard()
+	assert.Equal(t, 0, db.activeTxnNum)
+}
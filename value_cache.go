@@ -0,0 +1,84 @@
+package kv_go
+
+import (
+	"container/list"
+	"kv-go/data"
+	"sync"
+	"sync/atomic"
+)
+
+// 数据文件value的LRU缓存，key是数据在文件中的位置（fid + offset）
+// 数据文件只会追加，同一个位置的数据不会改变，Put和Delete写入的都是新的位置，缓存不会读到旧的数据
+// merge之后的数据文件在重启时才会被使用，运行中的db一直使用merge之前打开的文件，位置也不会变
+type valueCache struct {
+	mu       *sync.Mutex
+	capacity int64 // 缓存的value最多占用的字节数
+	size     int64
+	ll       *list.List // 最近访问的在前面
+	items    map[valueCacheKey]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+type valueCacheKey struct {
+	fid    uint32
+	offset int64
+}
+
+type valueCacheEntry struct {
+	key   valueCacheKey
+	value []byte
+}
+
+func newValueCache(capacity int64) *valueCache {
+	return &valueCache{
+		mu:       new(sync.Mutex),
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[valueCacheKey]*list.Element),
+	}
+}
+
+// 返回value的拷贝，调用者修改返回的数据不会影响缓存
+func (c *valueCache) get(pos *data.LogRecordPos) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[valueCacheKey{fid: pos.Fid, offset: pos.Offset}]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.ll.MoveToFront(elem)
+	value := elem.Value.(*valueCacheEntry).value
+	return append([]byte{}, value...), true
+}
+
+func (c *valueCache) put(pos *data.LogRecordPos, value []byte) {
+	// 比整个缓存还大的value不缓存
+	if int64(len(value)) > c.capacity {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := valueCacheKey{fid: pos.Fid, offset: pos.Offset}
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	entry := &valueCacheEntry{key: key, value: append([]byte{}, value...)}
+	c.items[key] = c.ll.PushFront(entry)
+	c.size += int64(len(value))
+
+	// 淘汰最久没有访问的数据
+	for c.size > c.capacity {
+		elem := c.ll.Back()
+		oldest := elem.Value.(*valueCacheEntry)
+		c.ll.Remove(elem)
+		delete(c.items, oldest.key)
+		c.size -= int64(len(oldest.value))
+	}
+}
@@ -0,0 +1,114 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueCache(t *testing.T) {
+	cache := newValueCache(10)
+
+	pos1 := &data.LogRecordPos{Fid: 0, Offset: 0}
+	pos2 := &data.LogRecordPos{Fid: 0, Offset: 100}
+	pos3 := &data.LogRecordPos{Fid: 1, Offset: 0}
+
+	_, ok := cache.get(pos1)
+	assert.False(t, ok)
+
+	cache.put(pos1, []byte("aaaa"))
+	cache.put(pos2, []byte("bbbb"))
+	value, ok := cache.get(pos1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("aaaa"), value)
+
+	// 修改返回的数据不影响缓存
+	value[0] = 'x'
+	value, ok = cache.get(pos1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("aaaa"), value)
+
+	// 超过容量时淘汰最久没有访问的pos2
+	cache.put(pos3, []byte("cccc"))
+	_, ok = cache.get(pos2)
+	assert.False(t, ok)
+	_, ok = cache.get(pos1)
+	assert.True(t, ok)
+	_, ok = cache.get(pos3)
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), cache.size)
+
+	// 比容量还大的value不缓存
+	cache.put(&data.LogRecordPos{Fid: 2}, []byte("01234567890"))
+	assert.Equal(t, 2, cache.ll.Len())
+
+	assert.Equal(t, uint64(4), cache.hits)
+	assert.Equal(t, uint64(2), cache.misses)
+}
+
+func TestDB_ValueCache(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-value-cache")
+	opts.DirPath = dir
+	opts.ValueCacheBytes = 1024 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(utils.GetTestKey(1), []byte("v1"))
+	assert.Nil(t, err)
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), val)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), val)
+
+	stat := db.Stat()
+	assert.Equal(t, uint64(1), stat.CacheHits)
+	assert.Equal(t, uint64(1), stat.CacheMisses)
+
+	// 覆盖写入和删除之后不会读到缓存中旧的数据
+	err = db.Put(utils.GetTestKey(1), []byte("v2"))
+	assert.Nil(t, err)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+
+	err = db.Delete(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 批量写入
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put(utils.GetTestKey(1), []byte("v3"))
+	err = wb.Commit()
+	assert.Nil(t, err)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v3"), val)
+
+	// merge之后
+	err = db.Merge()
+	assert.Nil(t, err)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v3"), val)
+
+	// 没有开启缓存时计数为0
+	opts2 := DefaultConfig
+	dir2, _ := os.MkdirTemp("", "bitcask-go-value-cache2")
+	opts2.DirPath = dir2
+	db2, err := Open(opts2)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	err = db2.Put(utils.GetTestKey(1), []byte("v1"))
+	assert.Nil(t, err)
+	_, err = db2.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), db2.Stat().CacheMisses)
+}
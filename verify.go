@@ -0,0 +1,236 @@
+package kv_go
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"kv-go/data"
+	"kv-go/fio"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 离线检查数据目录，不加文件锁，也不会修改目录中的文件
+// 数据文件、hint文件和merge完成文件都用mmap只读打开
+
+// 检查出的一个问题或者警告，Offset是问题数据在文件中的位置
+type VerifyProblem struct {
+	FileName string
+	Offset   int64
+	Reason   string
+}
+
+func (p VerifyProblem) String() string {
+	return fmt.Sprintf("%s offset %d: %s", p.FileName, p.Offset, p.Reason)
+}
+
+type VerifyReport struct {
+	DataFiles   int             // 检查的数据文件数量
+	Records     int             // 数据文件中完整的数据条数
+	HintRecords int             // hint文件中的索引条数
+	Problems    []VerifyProblem // crc错误、数据不完整等损坏
+	// 进程崩溃留下的没有完成的事务，Open时会忽略，不算损坏
+	Warnings []VerifyProblem
+}
+
+// 没有发现问题，只有警告也算正常
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) addProblem(fileName string, offset int64, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, VerifyProblem{
+		FileName: fileName,
+		Offset:   offset,
+		Reason:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *VerifyReport) addWarning(fileName string, offset int64, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, VerifyProblem{
+		FileName: fileName,
+		Offset:   offset,
+		Reason:   fmt.Sprintf(format, args...),
+	})
+}
+
+// 事务中第一条数据的位置，用于报告没有完成的事务
+type txnLocation struct {
+	fileName string
+	offset   int64
+}
+
+// 检查数据目录：数据文件的crc、没有完成的事务、hint文件中的索引是否指向有效的数据
+// 只有目录无法读取时才返回error，数据的问题和警告都记录在VerifyReport中
+func Verify(dirPath string) (*VerifyReport, error) {
+	if _, err := os.Stat(dirPath); err != nil {
+		return nil, err
+	}
+	fileIds, err := getDataFileIds(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	dataFiles := make(map[uint32]*data.DataFile)
+	defer func() {
+		for _, dataFile := range dataFiles {
+			_ = dataFile.Close()
+		}
+	}()
+
+	pendingTxns := make(map[uint64]txnLocation)
+	for _, fid := range fileIds {
+		dataFile, err := data.OpenDataFile(dirPath, uint32(fid), fio.MemoryMap)
+		if err != nil {
+			return nil, err
+		}
+		dataFiles[uint32(fid)] = dataFile
+		report.DataFiles++
+		if err := verifyDataFile(dataFile, report, pendingTxns); err != nil {
+			return nil, err
+		}
+	}
+	// 写入事务时崩溃会留下没有完成的事务，Open时不会加载，只作为警告
+	for seqNo, loc := range pendingTxns {
+		report.addWarning(loc.fileName, loc.offset, "transaction %d has no finish record", seqNo)
+	}
+
+	nonMergeFileId, hasMerge, err := verifyMergeFinishedFile(dirPath, report)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyHintFile(dirPath, dataFiles, hasMerge, nonMergeFileId, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func verifyDataFile(dataFile *data.DataFile, report *VerifyReport, pendingTxns map[uint64]txnLocation) error {
+	fileName := filepath.Base(data.GetDatafilePath("", dataFile.FileId))
+	fileSize, err := dataFile.IOManager.Size()
+	if err != nil {
+		return err
+	}
+
+	var offset int64 = 0
+	for {
+		logRecord, size, err := dataFile.Read(offset)
+		if err != nil {
+			if err == io.EOF {
+				// 读取到的数据不完整，或者文件末尾还有剩余的字节
+				if offset < fileSize {
+					report.addProblem(fileName, offset, "incomplete record, %d trailing bytes", fileSize-offset)
+				}
+				return nil
+			}
+			// crc错误之后无法确定下一条数据的位置，不再继续读取这个文件
+			report.addProblem(fileName, offset, "%v, %d bytes left unchecked", err, fileSize-offset)
+			return nil
+		}
+		report.Records++
+
+		_, seqNo := parseSeqLogRecordKey(logRecord.Key)
+		if seqNo != nonTxnSeqNo {
+			if logRecord.Type == data.LogRecordTxnFinished {
+				delete(pendingTxns, seqNo)
+			} else if _, ok := pendingTxns[seqNo]; !ok {
+				pendingTxns[seqNo] = txnLocation{fileName: fileName, offset: offset}
+			}
+		}
+		offset += size
+	}
+}
+
+// 返回merge完成文件中记录的nonMergeFileId
+func verifyMergeFinishedFile(dirPath string, report *VerifyReport) (uint32, bool, error) {
+	filePath := filepath.Join(dirPath, data.MergeFinishedFileName)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	mergeFinishedFile, err := openReadOnlyFile(filePath)
+	if err != nil {
+		return 0, false, err
+	}
+	defer mergeFinishedFile.Close()
+
+	record, _, err := mergeFinishedFile.Read(0)
+	if err != nil {
+		report.addProblem(data.MergeFinishedFileName, 0, "%v", err)
+		return 0, false, nil
+	}
+	fid, err := strconv.Atoi(string(record.Value))
+	if err != nil || fid < 0 {
+		report.addProblem(data.MergeFinishedFileName, 0, "invalid non-merge file id %q", record.Value)
+		return 0, false, nil
+	}
+	return uint32(fid), true, nil
+}
+
+// hint文件中的每条索引都要指向merge过的数据文件中key相同的完整数据
+func verifyHintFile(dirPath string, dataFiles map[uint32]*data.DataFile, hasMerge bool, nonMergeFileId uint32, report *VerifyReport) error {
+	filePath := filepath.Join(dirPath, data.HintFileName)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if hasMerge {
+			report.addProblem(data.HintFileName, 0, "hint file is missing")
+		}
+		return nil
+	}
+	hintFile, err := openReadOnlyFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer hintFile.Close()
+	fileSize, err := hintFile.IOManager.Size()
+	if err != nil {
+		return err
+	}
+
+	var offset int64 = 0
+	for {
+		logRecord, size, err := hintFile.Read(offset)
+		if err != nil {
+			if err != io.EOF {
+				report.addProblem(data.HintFileName, offset, "%v, %d bytes left unchecked", err, fileSize-offset)
+			} else if offset < fileSize {
+				report.addProblem(data.HintFileName, offset, "incomplete record, %d trailing bytes", fileSize-offset)
+			}
+			return nil
+		}
+		report.HintRecords++
+
+		pos := data.DecodeLogRecordPos(logRecord.Value)
+		dataFile, ok := dataFiles[pos.Fid]
+		var dataFileSize int64
+		if ok {
+			if dataFileSize, err = dataFile.IOManager.Size(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case !ok:
+			report.addProblem(data.HintFileName, offset, "key %q points to missing data file %d", logRecord.Key, pos.Fid)
+		case hasMerge && pos.Fid >= nonMergeFileId:
+			report.addProblem(data.HintFileName, offset, "key %q points to data file %d which was not merged", logRecord.Key, pos.Fid)
+		case pos.Offset < 0 || pos.Size == 0 || pos.Offset+int64(pos.Size) > dataFileSize:
+			report.addProblem(data.HintFileName, offset, "key %q points outside of data file %d: offset %d size %d", logRecord.Key, pos.Fid, pos.Offset, pos.Size)
+		default:
+			record, err := dataFile.ReadAt(pos)
+			if err != nil {
+				report.addProblem(data.HintFileName, offset, "key %q points to invalid record at %d:%d: %v", logRecord.Key, pos.Fid, pos.Offset, err)
+			} else if key, _ := parseSeqLogRecordKey(record.Key); !bytes.Equal(key, logRecord.Key) {
+				report.addProblem(data.HintFileName, offset, "key %q points to record of key %q at %d:%d", logRecord.Key, key, pos.Fid, pos.Offset)
+			}
+		}
+		offset += size
+	}
+}
+
+func openReadOnlyFile(filePath string) (*data.DataFile, error) {
+	ioManager, err := fio.NewMMapIOManager(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &data.DataFile{IOManager: ioManager}, nil
+}
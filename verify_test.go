@@ -0,0 +1,99 @@
+package kv_go
+
+import (
+	"kv-go/data"
+	"kv-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-verify")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer func() {
+		destroyDB(db)
+	}()
+	assert.Nil(t, err)
+
+	for i := 0; i < 500; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	for i := 500; i < 600; i++ {
+		assert.Nil(t, wb.Put(utils.GetTestKey(i), utils.RandomValue(128)))
+	}
+	assert.Nil(t, wb.Commit())
+	assert.Nil(t, db.Merge())
+	// merge文件在重启时加载
+	assert.Nil(t, db.Close())
+	db, err = Open(opts)
+	assert.Nil(t, err)
+
+	// 数据库打开时也可以检查
+	report, err := Verify(dir)
+	assert.Nil(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.True(t, report.DataFiles > 1)
+	assert.Equal(t, 500, report.HintRecords)
+
+	_, err = Verify(dir + "-not-exist")
+	assert.NotNil(t, err)
+}
+
+func TestVerify_Problems(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-verify-problems")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	fid, badOffset := db.activeFile.FileId, db.activeFile.WriteOffset
+	assert.Nil(t, db.Put(utils.GetTestKey(10), utils.RandomValue(128)))
+
+	// 没有完成的事务
+	txnOffset := db.activeFile.WriteOffset
+	record := &data.LogRecord{
+		Key:  createLogRecordKeyWithSeq(utils.GetTestKey(11), 100),
+		Type: data.LogRecordNormal,
+	}
+	_, err = db.appendLogRecord(record)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Sync())
+
+	// 没有完成的事务只是警告
+	report, err := Verify(dir)
+	assert.Nil(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, 1, len(report.Warnings))
+	assert.Equal(t, txnOffset, report.Warnings[0].Offset)
+
+	// 损坏第11条数据
+	filePath := data.GetDatafilePath(dir, fid)
+	f, err := os.OpenFile(filePath, os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("corrupted"), badOffset+20)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	report, err = Verify(dir)
+	assert.Nil(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, 10, report.Records)
+	assert.Equal(t, badOffset, report.Problems[0].Offset)
+	assert.Equal(t, data.GetDatafilePath("", fid), report.Problems[0].FileName)
+}
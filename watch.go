@@ -0,0 +1,136 @@
+package kv_go
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// 流程：
+// Watch创建一个watcher，写入数据之后在db.mu中调用notifyWatchers，按照写入顺序把事件放进每个watcher的队列
+// 每个watcher有一个goroutine把队列中的事件发送到channel，消费慢的watcher不会阻塞写入
+// WriteBatch和Txn在写入txnFinKey之后才产生事件，一个事务的所有修改在同一个事件中
+
+type EventType = byte
+
+const (
+	EventPut EventType = iota + 1
+	EventDelete
+)
+
+// 一个key的修改
+type EventEntry struct {
+	Type  EventType
+	Key   []byte
+	Value []byte // 删除时为空
+}
+
+// 一次写入产生的事件，批量写入的所有修改在同一个事件中
+type Event struct {
+	// 写入的序列号，单调递增，重启之后也不会重复，可以用来继续消费
+	// 批量写入时和磁盘上的seqNo一致，普通写入的序列号不写入数据文件，重启前后的序列号不连续
+	SeqNo   uint64
+	Entries []*EventEntry
+}
+
+type watcher struct {
+	prefix   []byte
+	mu       *sync.Mutex
+	queue    []Event
+	notifyCh chan struct{} // 队列中有新的事件
+	closeCh  chan struct{}
+	eventCh  chan Event
+	once     *sync.Once
+}
+
+// 订阅key以prefix开头的修改，prefix为空时订阅所有修改
+// 调用cancel之后channel会被关闭，db关闭时也会关闭所有channel
+func (db *DB) Watch(prefix []byte) (<-chan Event, func()) {
+	w := &watcher{
+		prefix:   prefix,
+		mu:       new(sync.Mutex),
+		notifyCh: make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+		eventCh:  make(chan Event),
+		once:     new(sync.Once),
+	}
+	go w.run()
+
+	db.mu.Lock()
+	db.watchers[w] = struct{}{}
+	db.mu.Unlock()
+
+	cancel := func() {
+		db.mu.Lock()
+		delete(db.watchers, w)
+		db.mu.Unlock()
+		w.close()
+	}
+	return w.eventCh, cancel
+}
+
+// 把事件放进所有关心这些key的watcher的队列中，调用时需要持有db.mu
+func (db *DB) notifyWatchers(seqNo uint64, entries []*EventEntry) {
+	if len(db.watchers) == 0 {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Key, entries[j].Key) < 0
+	})
+
+	for w := range db.watchers {
+		var matched []*EventEntry
+		for _, entry := range entries {
+			if bytes.HasPrefix(entry.Key, w.prefix) {
+				matched = append(matched, entry)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		w.push(Event{SeqNo: seqNo, Entries: matched})
+	}
+}
+
+func (w *watcher) push(event Event) {
+	w.mu.Lock()
+	w.queue = append(w.queue, event)
+	w.mu.Unlock()
+
+	select {
+	case w.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// 把队列中的事件依次发送到channel
+func (w *watcher) run() {
+	defer close(w.eventCh)
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			select {
+			case <-w.notifyCh:
+				continue
+			case <-w.closeCh:
+				return
+			}
+		}
+		event := w.queue[0]
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+
+		select {
+		case w.eventCh <- event:
+		case <-w.closeCh:
+			return
+		}
+	}
+}
+
+func (w *watcher) close() {
+	w.once.Do(func() {
+		close(w.closeCh)
+	})
+}
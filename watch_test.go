@@ -0,0 +1,135 @@
+package kv_go
+
+import (
+	"kv-go/utils"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receiveEvent(t *testing.T, ch <-chan Event) Event {
+	select {
+	case event, ok := <-ch:
+		assert.True(t, ok)
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	return Event{}
+}
+
+func TestDB_Watch(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-watch")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	ch, cancel := db.Watch([]byte("user/"))
+
+	// 普通写入和删除
+	err = db.Put([]byte("user/1"), []byte("a"))
+	assert.Nil(t, err)
+	err = db.Put([]byte("order/1"), []byte("b"))
+	assert.Nil(t, err)
+	err = db.Delete([]byte("user/1"))
+	assert.Nil(t, err)
+
+	event := receiveEvent(t, ch)
+	assert.Equal(t, 1, len(event.Entries))
+	assert.Equal(t, EventPut, event.Entries[0].Type)
+	assert.Equal(t, []byte("user/1"), event.Entries[0].Key)
+	assert.Equal(t, []byte("a"), event.Entries[0].Value)
+
+	event2 := receiveEvent(t, ch)
+	assert.Equal(t, EventDelete, event2.Entries[0].Type)
+	assert.Greater(t, event2.SeqNo, event.SeqNo)
+
+	// 批量写入产生一个事件，只包含订阅的前缀
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("user/2"), []byte("c"))
+	_ = wb.Put([]byte("user/3"), []byte("d"))
+	_ = wb.Put([]byte("order/2"), []byte("e"))
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	event3 := receiveEvent(t, ch)
+	assert.Equal(t, db.seqNo, event3.SeqNo)
+	assert.Equal(t, 2, len(event3.Entries))
+	assert.Equal(t, []byte("user/2"), event3.Entries[0].Key)
+	assert.Equal(t, []byte("user/3"), event3.Entries[1].Key)
+
+	// 取消之后channel被关闭
+	cancel()
+	err = db.Put([]byte("user/4"), []byte("f"))
+	assert.Nil(t, err)
+	for range ch {
+	}
+	assert.Equal(t, 0, len(db.watchers))
+
+	// 写入不会被没有消费的watcher阻塞，db关闭时关闭channel
+	ch2, _ := db.Watch(nil)
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(10))
+		assert.Nil(t, err)
+	}
+	event4 := receiveEvent(t, ch2)
+	assert.Equal(t, utils.GetTestKey(0), event4.Entries[0].Key)
+	err = db.Close()
+	assert.Nil(t, err)
+	for range ch2 {
+	}
+}
+
+func TestDB_WatchSeqNoAfterRestart(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-watch-restart")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	ch, cancel := db.Watch(nil)
+	for i := 0; i < 3; i++ {
+		err = db.Put(utils.GetTestKey(i), utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(utils.GetTestKey(0))
+	assert.Nil(t, err)
+	var lastSeqNo uint64
+	for i := 0; i < 4; i++ {
+		event := receiveEvent(t, ch)
+		assert.Greater(t, event.SeqNo, lastSeqNo)
+		lastSeqNo = event.SeqNo
+	}
+	cancel()
+
+	// 模拟异常退出，没有调用Close
+	_ = db.activeFile.Close()
+	_ = db.fileLock.Unlock()
+
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	ch2, cancel2 := db2.Watch(nil)
+	err = db2.Put(utils.GetTestKey(3), utils.GetTestKey(3))
+	assert.Nil(t, err)
+	event := receiveEvent(t, ch2)
+	assert.Greater(t, event.SeqNo, lastSeqNo)
+	lastSeqNo = event.SeqNo
+	cancel2()
+
+	// 正常关闭之后
+	err = db2.Close()
+	assert.Nil(t, err)
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	ch3, cancel3 := db3.Watch(nil)
+	defer cancel3()
+	err = db3.Delete(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	event = receiveEvent(t, ch3)
+	assert.Greater(t, event.SeqNo, lastSeqNo)
+}